@@ -0,0 +1,236 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/jroden2/holmes-go/pkg/domain"
+)
+
+type client struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewClient returns a client for the Holmes server at baseURL, e.g.
+// "http://localhost:8080". A nil httpClient means http.DefaultClient.
+func NewClient(baseURL string, httpClient *http.Client) Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: httpClient,
+	}
+}
+
+// Client calls the endpoints described by /api/openapi.json. Responses other
+// than 2xx are returned as *Error.
+type Client interface {
+	Compare(ctx context.Context, req domain.CompareRequest) (*domain.CompareResult, error)
+	CompareBatch(ctx context.Context, req domain.BatchRequest) (*domain.BatchResult, error)
+
+	// Asynchronous jobs
+	SubmitJob(ctx context.Context, req domain.CompareRequest) (*domain.Job, error)
+	SubmitBatchJob(ctx context.Context, req domain.BatchRequest) (*domain.Job, error)
+	Job(ctx context.Context, id string) (*domain.Job, error)
+	CompareJobResult(ctx context.Context, id string) (*domain.CompareResult, error)
+	BatchJobResult(ctx context.Context, id string) (*domain.BatchResult, error)
+	CancelJob(ctx context.Context, id string) (*domain.Job, error)
+
+	// Magic links
+	CreateMagicLink(ctx context.Context, a, b string) (string, error)
+	MagicLinkIDs(ctx context.Context) ([]string, error)
+	MagicLinkURL(id string) string
+
+	// Hashing
+	EncodeSha256(ctx context.Context, content string) (string, error)
+	ComputeSha256(ctx context.Context, content, comparison string) (bool, error)
+}
+
+// Error is a failed request. Message is the API error when the server sent
+// one, otherwise the start of the response body.
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("holmes: %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+func (c *client) Compare(ctx context.Context, req domain.CompareRequest) (*domain.CompareResult, error) {
+	var res domain.CompareResult
+	if err := c.postJSON(ctx, "/api/v1/compare", req, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// CompareBatch compares many pairs in one request. Failed pairs are reported
+// in their items rather than as an error.
+func (c *client) CompareBatch(ctx context.Context, req domain.BatchRequest) (*domain.BatchResult, error) {
+	var res domain.BatchResult
+	if err := c.postJSON(ctx, "/api/v1/compare/batch", req, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// SubmitJob queues a comparison and returns the job to poll with Job.
+func (c *client) SubmitJob(ctx context.Context, req domain.CompareRequest) (*domain.Job, error) {
+	var job domain.Job
+	if err := c.postJSON(ctx, "/api/v1/jobs", req, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (c *client) SubmitBatchJob(ctx context.Context, req domain.BatchRequest) (*domain.Job, error) {
+	var job domain.Job
+	if err := c.postJSON(ctx, "/api/v1/jobs/batch", req, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (c *client) Job(ctx context.Context, id string) (*domain.Job, error) {
+	var job domain.Job
+	if err := c.do(ctx, http.MethodGet, jobPath(id), "", nil, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// CompareJobResult returns the result of a done job submitted by SubmitJob.
+// A job that has not finished is an *Error with status 409.
+func (c *client) CompareJobResult(ctx context.Context, id string) (*domain.CompareResult, error) {
+	var res domain.CompareResult
+	if err := c.do(ctx, http.MethodGet, jobPath(id)+"/result", "", nil, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// BatchJobResult returns the result of a done job submitted by
+// SubmitBatchJob.
+func (c *client) BatchJobResult(ctx context.Context, id string) (*domain.BatchResult, error) {
+	var res domain.BatchResult
+	if err := c.do(ctx, http.MethodGet, jobPath(id)+"/result", "", nil, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (c *client) CancelJob(ctx context.Context, id string) (*domain.Job, error) {
+	var job domain.Job
+	if err := c.do(ctx, http.MethodDelete, jobPath(id), "", nil, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func jobPath(id string) string {
+	return "/api/v1/jobs/" + url.PathEscape(id)
+}
+
+// CreateMagicLink stores a and b and returns the link's ID.
+func (c *client) CreateMagicLink(ctx context.Context, a, b string) (string, error) {
+	var res struct {
+		ID string `json:"id"`
+	}
+	if err := c.postForm(ctx, "/magic/new", url.Values{"a": {a}, "b": {b}}, &res); err != nil {
+		return "", err
+	}
+	return res.ID, nil
+}
+
+func (c *client) MagicLinkIDs(ctx context.Context) ([]string, error) {
+	var res struct {
+		Keys []string `json:"keys"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/magic/peek", "", nil, &res); err != nil {
+		return nil, err
+	}
+	return res.Keys, nil
+}
+
+// MagicLinkURL returns the browser URL that opens the comparison stored as id.
+func (c *client) MagicLinkURL(id string) string {
+	return c.baseURL + "/magic?" + url.Values{"id": {id}}.Encode()
+}
+
+// EncodeSha256 returns the hex SHA-256 of content.
+func (c *client) EncodeSha256(ctx context.Context, content string) (string, error) {
+	var res struct {
+		Content string `json:"content"`
+	}
+	if err := c.postForm(ctx, "/sha/encode", url.Values{"content": {content}}, &res); err != nil {
+		return "", err
+	}
+	return res.Content, nil
+}
+
+// ComputeSha256 reports whether the hex SHA-256 of content is comparison.
+func (c *client) ComputeSha256(ctx context.Context, content, comparison string) (bool, error) {
+	var res struct {
+		Result bool `json:"result"`
+	}
+	if err := c.postForm(ctx, "/sha/compute", url.Values{"content": {content}, "comparison": {comparison}}, &res); err != nil {
+		return false, err
+	}
+	return res.Result, nil
+}
+
+func (c *client) postJSON(ctx context.Context, path string, in, out any) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	return c.do(ctx, http.MethodPost, path, "application/json", bytes.NewReader(body), out)
+}
+
+func (c *client) postForm(ctx context.Context, path string, form url.Values, out any) error {
+	return c.do(ctx, http.MethodPost, path, "application/x-www-form-urlencoded", strings.NewReader(form.Encode()), out)
+}
+
+// do sends the request and decodes a 2xx JSON response into out.
+func (c *client) do(ctx context.Context, method, path, contentType string, body io.Reader, out any) error {
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return responseError(resp)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("holmes: decoding %s response: %w", path, err)
+	}
+	return nil
+}
+
+func responseError(resp *http.Response) error {
+	blob, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	var apiErr domain.APIError
+	if json.Unmarshal(blob, &apiErr) == nil && apiErr.Error != "" {
+		return &Error{StatusCode: resp.StatusCode, Message: apiErr.Error}
+	}
+	return &Error{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(blob))}
+}
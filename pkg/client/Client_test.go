@@ -0,0 +1,104 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-contrib/sessions/cookie"
+	"github.com/gin-gonic/gin"
+	"github.com/jroden2/holmes-go/pkg/controllers/public"
+	"github.com/jroden2/holmes-go/pkg/domain"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupTestServer(t *testing.T) *httptest.Server {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("ProfilesFile", t.TempDir()+"/profiles.json")
+	logger := zerolog.New(os.Stdout)
+
+	r := gin.New()
+	r.Use(sessions.Sessions("mysession", cookie.NewStore([]byte("secret"))))
+	public.Routes(r.Group(""), &logger)
+
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestClient(t *testing.T) {
+	srv := setupTestServer(t)
+	c := NewClient(srv.URL+"/", srv.Client())
+	ctx := context.Background()
+
+	t.Run("compare", func(t *testing.T) {
+		res, err := c.Compare(ctx, domain.CompareRequest{A: `{"port":80}`, B: `{"port":8080}`, Mode: "json"})
+		require.NoError(t, err)
+		assert.Equal(t, "json", res.Mode)
+		require.Len(t, res.Changes, 1)
+		assert.Equal(t, domain.PathChange{Path: "port", A: "80", B: "8080", Status: "changed"}, res.Changes[0])
+	})
+
+	t.Run("compare error", func(t *testing.T) {
+		_, err := c.Compare(ctx, domain.CompareRequest{A: "x", B: "y", Mode: "nope"})
+		var apiErr *Error
+		require.True(t, errors.As(err, &apiErr), "error = %v", err)
+		assert.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+		assert.Contains(t, apiErr.Message, `unknown mode "nope"`)
+	})
+
+	t.Run("jobs", func(t *testing.T) {
+		job, err := c.SubmitJob(ctx, domain.CompareRequest{A: "one\ntwo", B: "one\nthree"})
+		require.NoError(t, err)
+		assert.Equal(t, "compare", job.Kind)
+
+		require.Eventually(t, func() bool {
+			job, err = c.Job(ctx, job.ID)
+			require.NoError(t, err)
+			return job.IsFinished()
+		}, 5*time.Second, 5*time.Millisecond)
+		assert.Equal(t, domain.JobDone, job.State)
+
+		res, err := c.CompareJobResult(ctx, job.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 1, res.Stats.LinesChanged)
+
+		_, err = c.CancelJob(ctx, job.ID)
+		var apiErr *Error
+		require.True(t, errors.As(err, &apiErr), "error = %v", err)
+		assert.Equal(t, http.StatusConflict, apiErr.StatusCode)
+	})
+
+	t.Run("magic links", func(t *testing.T) {
+		id, err := c.CreateMagicLink(ctx, "left", "right")
+		require.NoError(t, err)
+		assert.Len(t, id, 8)
+
+		ids, err := c.MagicLinkIDs(ctx)
+		require.NoError(t, err)
+		assert.Contains(t, ids, id)
+		assert.Equal(t, srv.URL+"/magic?id="+id, c.MagicLinkURL(id))
+	})
+
+	t.Run("hashing", func(t *testing.T) {
+		const sum = "d5579c46dfcc7f18207013e65b44e4cb4e2c2298f4ac457ba8f82743f31e930b"
+		got, err := c.EncodeSha256(ctx, "test string")
+		require.NoError(t, err)
+		assert.Equal(t, sum, got)
+
+		ok, err := c.ComputeSha256(ctx, "test string", sum)
+		require.NoError(t, err)
+		assert.True(t, ok)
+
+		ok, err = c.ComputeSha256(ctx, "other string", sum)
+		require.NoError(t, err)
+		assert.False(t, ok)
+	})
+}
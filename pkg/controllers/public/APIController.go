@@ -0,0 +1,455 @@
+package public
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jroden2/holmes-go/pkg/domain"
+	"github.com/jroden2/holmes-go/pkg/services"
+	"github.com/jroden2/holmes-go/pkg/utils"
+	"github.com/rs/zerolog"
+)
+
+// maxAPIBody bounds API request bodies.
+const maxAPIBody = 40 << 20
+
+// maxBatchPairs bounds the number of pairs in one batch request.
+const maxBatchPairs = 1000
+
+// openAPISpec describes the JSON API, magic link and hashing endpoints.
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+type apiController struct {
+	logger   *zerolog.Logger
+	profiles services.ProfileService
+	jobs     services.JobService
+	results  services.ResultCacheService
+}
+
+func NewAPIController(logger *zerolog.Logger, profiles services.ProfileService, jobs services.JobService, results services.ResultCacheService) APIController {
+	return &apiController{
+		logger:   logger,
+		profiles: profiles,
+		jobs:     jobs,
+		results:  results,
+	}
+}
+
+// APIController serves the versioned JSON API. Errors are returned as
+// domain.APIError with a matching status code.
+type APIController interface {
+	Compare(ctx *gin.Context)
+	CompareBatch(ctx *gin.Context)
+	OpenAPI(ctx *gin.Context)
+
+	// Asynchronous jobs
+	SubmitJob(ctx *gin.Context)
+	SubmitBatchJob(ctx *gin.Context)
+	Job(ctx *gin.Context)
+	JobResult(ctx *gin.Context)
+	CancelJob(ctx *gin.Context)
+}
+
+// Compare runs one comparison. Malformed requests are 400, oversized bodies
+// 413 and inputs that fail to parse in the requested mode 422.
+func (c *apiController) Compare(ctx *gin.Context) {
+	var req domain.CompareRequest
+	if !bindAPIRequest(ctx, &req) {
+		return
+	}
+
+	res, status, err := c.compare(ctx.Request.Context(), req, nil)
+	if err != nil {
+		apiError(ctx, status, err.Error())
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
+// SubmitJob queues one comparison and answers 202 with the job, whose status
+// is then polled at Location. Requests are validated before queuing.
+func (c *apiController) SubmitJob(ctx *gin.Context) {
+	var req domain.CompareRequest
+	if !bindAPIRequest(ctx, &req) {
+		return
+	}
+	data, mode, err := prepareRequest(c.profiles, req)
+	if err != nil {
+		apiError(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	c.submitJob(ctx, "compare", func(jobCtx context.Context, progress func(done, total int)) (any, error) {
+		progress(0, compareStages)
+		res, status, err := c.run(jobCtx, data, mode, req, progress)
+		if err != nil {
+			return nil, &statusError{status: status, err: err}
+		}
+		return res, nil
+	})
+}
+
+// SubmitBatchJob queues a batch; progress counts the pairs compared.
+func (c *apiController) SubmitBatchJob(ctx *gin.Context) {
+	var req domain.BatchRequest
+	if !bindBatchRequest(ctx, &req) {
+		return
+	}
+
+	c.submitJob(ctx, "batch", func(jobCtx context.Context, progress func(done, total int)) (any, error) {
+		progress(0, len(req.Pairs))
+		return c.compareBatch(jobCtx, req.Pairs, batchWorkers(req.Concurrency), progress), nil
+	})
+}
+
+func (c *apiController) submitJob(ctx *gin.Context, kind string, fn services.JobFunc) {
+	job, err := c.jobs.Submit(kind, fn)
+	if errors.Is(err, services.ErrTooManyJobs) {
+		apiError(ctx, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	if err != nil {
+		c.logger.Error().Err(err).Msg("Failed to submit job")
+		apiError(ctx, http.StatusInternalServerError, err.Error())
+		return
+	}
+	ctx.Header("Location", "/api/v1/jobs/"+job.ID)
+	ctx.JSON(http.StatusAccepted, job)
+}
+
+// Job returns the status and progress of a job.
+func (c *apiController) Job(ctx *gin.Context) {
+	job, ok := c.jobs.Get(ctx.Param("id"))
+	if !ok {
+		apiError(ctx, http.StatusNotFound, services.ErrJobNotFound.Error())
+		return
+	}
+	ctx.JSON(http.StatusOK, job)
+}
+
+// JobResult returns the result of a done job. A job still queued or running
+// is 409 and a cancelled one 410; a failed job answers with the status its
+// comparison would have had synchronously.
+func (c *apiController) JobResult(ctx *gin.Context) {
+	res, err := c.jobs.Result(ctx.Param("id"))
+	if err != nil {
+		var se *statusError
+		switch {
+		case errors.Is(err, services.ErrJobNotFound):
+			apiError(ctx, http.StatusNotFound, err.Error())
+		case errors.Is(err, services.ErrJobNotFinished):
+			apiError(ctx, http.StatusConflict, err.Error())
+		case errors.Is(err, services.ErrJobCancelled):
+			apiError(ctx, http.StatusGone, err.Error())
+		case errors.As(err, &se):
+			apiError(ctx, se.status, se.Error())
+		default:
+			apiError(ctx, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
+// CancelJob cancels a queued or running job and returns its status.
+func (c *apiController) CancelJob(ctx *gin.Context) {
+	job, err := c.jobs.Cancel(ctx.Param("id"))
+	if errors.Is(err, services.ErrJobNotFound) {
+		apiError(ctx, http.StatusNotFound, err.Error())
+		return
+	}
+	if errors.Is(err, services.ErrJobFinished) {
+		apiError(ctx, http.StatusConflict, err.Error())
+		return
+	}
+	ctx.JSON(http.StatusOK, job)
+}
+
+// statusError carries the status code a failed job reports with its error.
+type statusError struct {
+	status int
+	err    error
+}
+
+func (e *statusError) Error() string { return e.err.Error() }
+func (e *statusError) Unwrap() error { return e.err }
+
+// CompareBatch runs every pair of a batch on a bounded pool of workers. A
+// pair that fails is reported in its item; the batch itself only fails when
+// the request is malformed.
+func (c *apiController) CompareBatch(ctx *gin.Context) {
+	var req domain.BatchRequest
+	if !bindBatchRequest(ctx, &req) {
+		return
+	}
+
+	res := c.compareBatch(ctx.Request.Context(), req.Pairs, batchWorkers(req.Concurrency), nil)
+	ctx.JSON(http.StatusOK, res)
+}
+
+// bindBatchRequest decodes and checks a batch, writing the error response and
+// returning false when it is not valid.
+func bindBatchRequest(ctx *gin.Context, req *domain.BatchRequest) bool {
+	if !bindAPIRequest(ctx, req) {
+		return false
+	}
+	if len(req.Pairs) == 0 {
+		apiError(ctx, http.StatusBadRequest, "pairs is empty")
+		return false
+	}
+	if len(req.Pairs) > maxBatchPairs {
+		apiError(ctx, http.StatusRequestEntityTooLarge, fmt.Sprintf("batch has more than %d pairs", maxBatchPairs))
+		return false
+	}
+	return true
+}
+
+// batchWorkers clamps the requested concurrency to 1..GOMAXPROCS.
+func batchWorkers(n int) int {
+	limit := runtime.GOMAXPROCS(0)
+	if n <= 0 || n > limit {
+		return limit
+	}
+	return n
+}
+
+// compareBatch compares the pairs with the given number of workers, calling
+// progress, when set, as each pair completes. Pairs not started when ctx is
+// done are reported as failed.
+func (c *apiController) compareBatch(ctx context.Context, pairs []domain.BatchPair, workers int, progress func(done, total int)) domain.BatchResult {
+	items := make([]domain.BatchItem, len(pairs))
+	jobs := make(chan int)
+	var completed atomic.Int64
+	var wg sync.WaitGroup
+	for range min(workers, len(pairs)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				items[i] = c.comparePair(ctx, i, pairs[i])
+				if n := completed.Add(1); progress != nil {
+					progress(int(n), len(pairs))
+				}
+			}
+		}()
+	}
+
+	next := 0
+dispatch:
+	for ; next < len(pairs) && ctx.Err() == nil; next++ {
+		select {
+		case jobs <- next:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(jobs)
+	for i := next; i < len(pairs); i++ {
+		items[i] = domain.BatchItem{Index: i, ID: pairs[i].ID, Status: http.StatusServiceUnavailable, Error: ctx.Err().Error()}
+	}
+	wg.Wait()
+
+	return domain.BatchResult{Summary: batchSummary(items), Results: items}
+}
+
+// comparePair compares the i-th pair of a batch. A panic fails only this
+// pair, with status 500, since batch workers run outside gin's recovery.
+func (c *apiController) comparePair(ctx context.Context, i int, pair domain.BatchPair) (item domain.BatchItem) {
+	defer func() {
+		if r := recover(); r != nil {
+			c.logger.Error().Interface("panic", r).Bytes("stack", debug.Stack()).Str("pair", pair.ID).Msg("Batch pair panicked")
+			item = domain.BatchItem{Index: i, ID: pair.ID, Status: http.StatusInternalServerError, Error: fmt.Sprintf("comparison panicked: %v", r)}
+		}
+	}()
+
+	item = domain.BatchItem{Index: i, ID: pair.ID, Status: http.StatusOK}
+	res, status, err := c.compare(ctx, pair.CompareRequest, nil)
+	if err != nil {
+		item.Status, item.Error = status, err.Error()
+	} else {
+		item.Result = &res
+	}
+	return item
+}
+
+func batchSummary(items []domain.BatchItem) domain.BatchSummary {
+	sum := domain.BatchSummary{Total: len(items)}
+	var similarity float64
+	compared := 0
+	for _, item := range items {
+		switch {
+		case item.Result == nil:
+			sum.Failed++
+			continue
+		case item.Result.ExactMatch || item.Result.NormalizedMatch:
+			sum.Matched++
+		default:
+			sum.Different++
+		}
+		if item.Result.Stats != nil {
+			similarity += item.Result.Stats.Similarity
+			compared++
+		}
+	}
+	if compared > 0 {
+		sum.Similarity = similarity / float64(compared)
+	}
+	return sum
+}
+
+// OpenAPI serves the OpenAPI 3 description of the HTTP API.
+func (c *apiController) OpenAPI(ctx *gin.Context) {
+	ctx.Data(http.StatusOK, "application/json; charset=utf-8", openAPISpec)
+}
+
+// compare validates req, runs it and returns the result, or the status code
+// and error to report.
+func (c *apiController) compare(ctx context.Context, req domain.CompareRequest, progress func(done, total int)) (domain.CompareResult, int, error) {
+	data, mode, err := prepareRequest(c.profiles, req)
+	if err != nil {
+		return domain.CompareResult{}, http.StatusBadRequest, err
+	}
+	return c.run(ctx, data, mode, req, progress)
+}
+
+// prepareRequest validates req and returns the page data and mode to compare
+// with. Any error is a bad request.
+func prepareRequest(profiles services.ProfileService, req domain.CompareRequest) (domain.PageData, string, error) {
+	data, mode, err := pageDataFromRequest(req)
+	if err != nil {
+		return data, "", err
+	}
+	if err := utils.CheckNormalizeRules(req.Options.Rules); err != nil {
+		return data, "", fmt.Errorf("options.rules: %w", err)
+	}
+	if data.NormalizeRules, err = resolveRules(profiles, req.Options.Profile, req.Options.Rules); err != nil {
+		return data, "", fmt.Errorf("options.profile: %w", err)
+	}
+	return data, mode, nil
+}
+
+// run compares the prepared request, reporting each stage to progress when
+// set. Inputs that fail to parse are 422 and a comparison stopped by ctx 503.
+func (c *apiController) run(ctx context.Context, data domain.PageData, mode string, req domain.CompareRequest, progress func(done, total int)) (domain.CompareResult, int, error) {
+	if err := compareCached(ctx, c.results, &data, mode, data.A, data.B, req.A, req.B, progress); err != nil {
+		return domain.CompareResult{}, http.StatusServiceUnavailable, err
+	}
+	if data.Error != "" {
+		return domain.CompareResult{}, http.StatusUnprocessableEntity, errors.New(data.Error)
+	}
+	return compareResult(data), http.StatusOK, nil
+}
+
+// bindAPIRequest decodes the JSON body into v, writing the error response and
+// returning false when it cannot.
+func bindAPIRequest(ctx *gin.Context, v any) bool {
+	ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxAPIBody)
+	if err := ctx.ShouldBindJSON(v); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			apiError(ctx, http.StatusRequestEntityTooLarge, fmt.Sprintf("request body is larger than %d bytes", maxAPIBody))
+			return false
+		}
+		apiError(ctx, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+		return false
+	}
+	return true
+}
+
+func apiError(ctx *gin.Context, status int, msg string) {
+	ctx.JSON(status, domain.APIError{Error: msg})
+}
+
+// pageDataFromRequest maps an API request onto the form's PageData and
+// returns the mode to compare in. An empty mode means auto.
+func pageDataFromRequest(req domain.CompareRequest) (domain.PageData, string, error) {
+	mode := req.Mode
+	if mode == "" {
+		mode = "auto"
+	}
+	auto := mode == "auto"
+	if _, ok := modeLabels[mode]; !ok && !auto && mode != "text" {
+		return domain.PageData{}, "", fmt.Errorf("unknown mode %q", req.Mode)
+	}
+	if auto {
+		mode = "text"
+	}
+
+	o := req.Options
+	data := domain.PageData{
+		A:          strings.TrimRight(req.A, "\n"),
+		B:          strings.TrimRight(req.B, "\n"),
+		Mode:       mode,
+		Auto:       auto,
+		IgnoreWS:   o.IgnoreWS,
+		IgnoreCase: o.IgnoreCase,
+
+		IgnoreTrailingWS: o.IgnoreTrailingWS,
+		IgnoreWSAmount:   o.IgnoreWSAmount,
+		IgnoreAllWS:      o.IgnoreAllWS,
+		IgnoreBlankLines: o.IgnoreBlankLines,
+		TabWidth:         min(max(o.TabWidth, 0), 16),
+
+		IgnoreClassOrder:  o.IgnoreClassOrder,
+		IgnoreScriptStyle: o.IgnoreScriptStyle,
+		IgnoreComments:    o.IgnoreComments,
+		JWTKey:            strings.TrimSpace(o.JWTKey),
+		Decode:            o.Decode,
+		ImageTolerance:    min(max(o.ImageTolerance, 0), 255),
+		Profile:           o.Profile,
+	}
+	return data, mode, nil
+}
+
+// compareResult converts the outcome of compareInto to the API response.
+// Hex dump rows are HTML only, so binary results carry byte counts instead.
+func compareResult(data domain.PageData) domain.CompareResult {
+	res := domain.CompareResult{
+		Mode:            data.Mode,
+		Warning:         data.DetectWarning,
+		ExactMatch:      data.ExactMatch,
+		NormalizedMatch: data.NormalizedMatch,
+		A: domain.InputSummary{
+			Length:      data.ALen,
+			SHA256:      data.AHash,
+			Detected:    data.ADetected,
+			DecodeChain: data.ADecodeChain,
+			RuleMatches: data.RuleMatchesA,
+		},
+		B: domain.InputSummary{
+			Length:      data.BLen,
+			SHA256:      data.BHash,
+			Detected:    data.BDetected,
+			DecodeChain: data.BDecodeChain,
+			RuleMatches: data.RuleMatchesB,
+		},
+		Stats:      data.Stats,
+		SetStats:   data.SetStats,
+		Image:      data.Image,
+		CharIssues: data.CharIssues,
+		Changes:    data.Changes,
+		Rows:       []domain.DiffRow{},
+	}
+	if res.Changes == nil {
+		res.Changes = []domain.PathChange{}
+	}
+	if data.Mode == "binary" {
+		res.Binary = &domain.BinaryDiff{DiffBytes: data.DiffBytes, FirstDiffOffset: data.FirstDiffOffset}
+		return res
+	}
+	for _, row := range data.LineDiff {
+		res.Rows = append(res.Rows, domain.DiffRow{Line: row.LineNum, A: row.A, B: row.B, Status: row.Status})
+	}
+	return res
+}
@@ -0,0 +1,545 @@
+package public
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jroden2/holmes-go/pkg/domain"
+	"github.com/jroden2/holmes-go/pkg/services"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupTestAPIController(t *testing.T) *gin.Engine {
+	t.Setenv("ProfilesFile", t.TempDir()+"/profiles.json")
+	logger := zerolog.New(os.Stdout)
+	ac := newTestAPIController(&logger)
+
+	r := gin.New()
+	r.POST("/api/v1/compare", ac.Compare)
+	r.POST("/api/v1/compare/batch", ac.CompareBatch)
+	r.POST("/api/v1/jobs", ac.SubmitJob)
+	r.POST("/api/v1/jobs/batch", ac.SubmitBatchJob)
+	r.GET("/api/v1/jobs/:id", ac.Job)
+	r.GET("/api/v1/jobs/:id/result", ac.JobResult)
+	r.DELETE("/api/v1/jobs/:id", ac.CancelJob)
+	return r
+}
+
+func newTestAPIController(logger *zerolog.Logger) APIController {
+	return NewAPIController(logger,
+		services.NewProfileService(logger, services.DefaultProfilesPath()),
+		services.NewJobService(logger, runtime.GOMAXPROCS(0), services.DefaultJobTimeout()),
+		services.NewResultCacheService(logger, services.DefaultResultCacheSize()))
+}
+
+func postJSON(r *gin.Engine, path, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestAPICompare(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name  string
+		body  string
+		check func(*testing.T, domain.CompareResult)
+	}{
+		{
+			name: "text rows and stats",
+			body: `{"a":"one\ntwo\n","b":"one\nthree","mode":"text"}`,
+			check: func(t *testing.T, res domain.CompareResult) {
+				assert.Equal(t, "text", res.Mode)
+				assert.False(t, res.ExactMatch)
+				require.Len(t, res.Rows, 2)
+				assert.Equal(t, domain.DiffRow{Line: 2, A: "two", B: "three", Status: "changed"}, res.Rows[1])
+				require.NotNil(t, res.Stats)
+				assert.Equal(t, 1, res.Stats.LinesChanged)
+				assert.Equal(t, len("one\ntwo"), res.A.Length)
+				assert.Len(t, res.A.SHA256, 64)
+			},
+		},
+		{
+			name: "auto detects json",
+			body: `{"a":"{\"port\":80,\"host\":\"a\"}","b":"{\"host\":\"a\",\"port\":8080}"}`,
+			check: func(t *testing.T, res domain.CompareResult) {
+				assert.Equal(t, "json", res.Mode)
+				assert.Equal(t, "JSON", res.A.Detected)
+				assert.Equal(t, []domain.PathChange{{Path: "port", A: "80", B: "8080", Status: "changed"}}, res.Changes)
+			},
+		},
+		{
+			name: "html siblings beyond the alignment budget",
+			body: func() string {
+				// 2400 siblings on each side that share no prefix or suffix
+				a, b := strings.Repeat("<li>x</li><p>x</p>", 1200), strings.Repeat("<p>x</p><li>x</li>", 1200)
+				blob, _ := json.Marshal(domain.CompareRequest{A: "<ul>" + a + "</ul>", B: "<ul>" + b + "</ul>", Mode: "html"})
+				return string(blob)
+			}(),
+			check: func(t *testing.T, res domain.CompareResult) {
+				assert.Len(t, res.Changes, 4800)
+			},
+		},
+		{
+			name: "rows keep input line numbers without blank lines",
+			body: `{"a":"one\n\ntwo\nthree","b":"one\ntwo\n\n\nTHREE\nfour","mode":"text","options":{"ignore_blank_lines":true}}`,
+			check: func(t *testing.T, res domain.CompareResult) {
+				assert.Equal(t, []domain.DiffRow{
+					{Line: 1, A: "one", B: "one", Status: "same"},
+					{Line: 3, A: "two", B: "two", Status: "same"},
+					{Line: 4, A: "three", B: "THREE", Status: "changed"},
+					{Line: 6, B: "four", Status: "added"},
+				}, res.Rows)
+			},
+		},
+		{
+			name: "json null is not a missing key",
+			body: `{"a":"{\"a\":null,\"b\":1}","b":"{\"b\":1}","mode":"json"}`,
+			check: func(t *testing.T, res domain.CompareResult) {
+				assert.Equal(t, []domain.PathChange{{Path: "a", A: "null", Status: "removed"}}, res.Changes)
+			},
+		},
+		{
+			name: "json null changed to a value",
+			body: `{"a":"{\"a\":null}","b":"{\"a\":1}","mode":"json"}`,
+			check: func(t *testing.T, res domain.CompareResult) {
+				assert.Equal(t, []domain.PathChange{{Path: "a", A: "null", B: "1", Status: "changed"}}, res.Changes)
+			},
+		},
+		{
+			name: "json top-level null changed to an object",
+			body: `{"a":"null","b":"{\"a\":1}","mode":"json"}`,
+			check: func(t *testing.T, res domain.CompareResult) {
+				assert.Equal(t, []domain.PathChange{{Path: "", A: "null", B: "{\"a\":1}", Status: "changed"}}, res.Changes)
+			},
+		},
+		{
+			name: "json top-level null against empty input",
+			body: `{"a":"null","b":"","mode":"json"}`,
+			check: func(t *testing.T, res domain.CompareResult) {
+				assert.Equal(t, []domain.PathChange{{Path: "", A: "null", Status: "removed"}}, res.Changes)
+			},
+		},
+		{
+			name: "yaml null document against empty input",
+			body: `{"a":"~","b":"","mode":"yaml"}`,
+			check: func(t *testing.T, res domain.CompareResult) {
+				assert.Equal(t, []domain.PathChange{{Path: "", A: "null", Status: "removed"}}, res.Changes)
+			},
+		},
+		{
+			name: "options and rules",
+			body: `{"a":"build-12  ok","b":"build-13 ok","mode":"text","options":{"ignore_ws_amount":true,"rules":[{"pattern":"build-\\d+","replace":"build-N"}]}}`,
+			check: func(t *testing.T, res domain.CompareResult) {
+				assert.False(t, res.ExactMatch)
+				assert.True(t, res.NormalizedMatch)
+				assert.Equal(t, 1, res.A.RuleMatches)
+			},
+		},
+		{
+			name: "binary via base64",
+			body: `{"a":"AAE=","b":"AAI=","mode":"binary","options":{"decode":"base64"}}`,
+			check: func(t *testing.T, res domain.CompareResult) {
+				require.NotNil(t, res.Binary)
+				assert.Equal(t, domain.BinaryDiff{DiffBytes: 1, FirstDiffOffset: 1}, *res.Binary)
+				assert.Empty(t, res.Rows)
+				assert.Equal(t, []string{"base64"}, res.A.DecodeChain)
+			},
+		},
+		{
+			name: "set mode",
+			body: `{"a":"x\ny","b":"y\nz","mode":"set"}`,
+			check: func(t *testing.T, res domain.CompareResult) {
+				require.NotNil(t, res.SetStats)
+				assert.Equal(t, 1, res.SetStats.OnlyA)
+				assert.Len(t, res.Changes, 2)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := setupTestAPIController(t)
+			w := postJSON(r, "/api/v1/compare", tt.body)
+
+			require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+			assert.Contains(t, w.Header().Get("Content-Type"), "application/json")
+			var res domain.CompareResult
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+			tt.check(t, res)
+		})
+	}
+}
+
+func TestAPIRun_Cancelled(t *testing.T) {
+	t.Setenv("ProfilesFile", t.TempDir()+"/profiles.json")
+	logger := zerolog.New(os.Stdout)
+	ac := newTestAPIController(&logger).(*apiController)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req := domain.CompareRequest{A: "{\"a\":1}", B: "{\"a\":2}", Mode: "json"}
+	data, mode, err := prepareRequest(ac.profiles, req)
+	require.NoError(t, err)
+
+	// Cancel once the first stage finishes
+	var stages []int
+	_, status, err := ac.run(ctx, data, mode, req, func(done, total int) {
+		assert.Equal(t, compareStages, total)
+		stages = append(stages, done)
+		cancel()
+	})
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, []int{1}, stages)
+
+	// The stopped comparison was not cached
+	_, ok := ac.results.Get(resultKey(data, mode, data.A, data.B, req.A, req.B))
+	assert.False(t, ok)
+}
+
+func TestAPICompare_SharedResultCache(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	bc, r := setupTestController()
+	logger := zerolog.New(os.Stdout)
+	results := bc.(*baseController).results
+	ac := NewAPIController(&logger, bc.(*baseController).profiles, services.NewJobService(&logger, 1, time.Minute), results)
+	r.POST("/compare", bc.Compare)
+	r.POST("/api/v1/compare", ac.Compare)
+
+	form := url.Values{"a": {"shared one"}, "b": {"shared two"}, "mode": {"text"}}
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/compare", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	// Replace the result the UI cached to show that the API reuses it
+	key := resultKey(domain.PageData{Mode: "text"}, "text", "shared one", "shared two", "shared one", "shared two")
+	_, ok := results.Get(key)
+	require.True(t, ok, "result was not cached")
+	results.Add(key, domain.PageData{Mode: "text", Error: "served from cache"})
+
+	w = postJSON(r, "/api/v1/compare", `{"a":"shared one","b":"shared two","mode":"text"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), "served from cache")
+}
+
+func TestAPICompare_Errors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		error  string
+	}{
+		{name: "malformed body", body: `{"a":`, status: http.StatusBadRequest, error: "invalid JSON body"},
+		{name: "unknown mode", body: `{"a":"x","b":"y","mode":"nope"}`, status: http.StatusBadRequest, error: `unknown mode "nope"`},
+		{name: "invalid rule", body: `{"a":"x","b":"y","options":{"rules":[{"pattern":"("}]}}`, status: http.StatusBadRequest, error: "options.rules: rule 1"},
+		{name: "unknown profile", body: `{"a":"x","b":"y","options":{"profile":"missing"}}`, status: http.StatusBadRequest, error: `options.profile: profile "missing" not found`},
+		{name: "input fails to parse", body: `{"a":"{bad","b":"{}","mode":"json"}`, status: http.StatusUnprocessableEntity, error: "JSON parse error for A"},
+		{name: "body too large", body: `{"a":"` + strings.Repeat("x", maxAPIBody) + `"}`, status: http.StatusRequestEntityTooLarge, error: "larger than"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := setupTestAPIController(t)
+			w := postJSON(r, "/api/v1/compare", tt.body)
+
+			assert.Equal(t, tt.status, w.Code)
+			var apiErr domain.APIError
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &apiErr))
+			assert.Contains(t, apiErr.Error, tt.error)
+		})
+	}
+}
+
+func TestAPICompareBatch(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := setupTestAPIController(t)
+
+	body := `{"concurrency":2,"pairs":[
+		{"id":"same","a":"x","b":"x"},
+		{"id":"json","a":"{\"port\":80}","b":"{\"port\":8080}","mode":"json"},
+		{"id":"bad-mode","a":"x","b":"y","mode":"nope"},
+		{"id":"bad-json","a":"{bad","b":"{}","mode":"json"},
+		{"id":"case","a":"Hello","b":"hello","options":{"ignore_case":true}}
+	]}`
+	w := postJSON(r, "/api/v1/compare/batch", body)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var res domain.BatchResult
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+	require.Len(t, res.Results, 5)
+	for i, id := range []string{"same", "json", "bad-mode", "bad-json", "case"} {
+		assert.Equal(t, i, res.Results[i].Index)
+		assert.Equal(t, id, res.Results[i].ID)
+	}
+
+	assert.True(t, res.Results[0].Result.ExactMatch)
+	require.NotNil(t, res.Results[1].Result)
+	assert.Len(t, res.Results[1].Result.Changes, 1)
+	assert.Equal(t, http.StatusBadRequest, res.Results[2].Status)
+	assert.Contains(t, res.Results[2].Error, "unknown mode")
+	assert.Nil(t, res.Results[2].Result)
+	assert.Equal(t, http.StatusUnprocessableEntity, res.Results[3].Status)
+	assert.True(t, res.Results[4].Result.NormalizedMatch)
+
+	assert.Equal(t, 5, res.Summary.Total)
+	assert.Equal(t, 2, res.Summary.Matched)
+	assert.Equal(t, 1, res.Summary.Different)
+	assert.Equal(t, 2, res.Summary.Failed)
+	assert.Greater(t, res.Summary.Similarity, 0.0)
+}
+
+func TestAPICompareBatch_Errors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tooMany := `{"pairs":[` + strings.Repeat(`{"a":"x","b":"y"},`, maxBatchPairs) + `{"a":"x","b":"y"}]}`
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		error  string
+	}{
+		{name: "no pairs", body: `{"pairs":[]}`, status: http.StatusBadRequest, error: "pairs is empty"},
+		{name: "malformed body", body: `{"pairs":`, status: http.StatusBadRequest, error: "invalid JSON body"},
+		{name: "too many pairs", body: tooMany, status: http.StatusRequestEntityTooLarge, error: "more than 1000 pairs"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := setupTestAPIController(t)
+			w := postJSON(r, "/api/v1/compare/batch", tt.body)
+
+			assert.Equal(t, tt.status, w.Code)
+			var apiErr domain.APIError
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &apiErr))
+			assert.Contains(t, apiErr.Error, tt.error)
+		})
+	}
+}
+
+func TestCompareBatch_Panic(t *testing.T) {
+	logger := zerolog.New(os.Stdout)
+	// Without a profile service, a pair naming a profile panics
+	ac := NewAPIController(&logger, nil, services.NewJobService(&logger, 1, time.Minute),
+		services.NewResultCacheService(&logger, services.DefaultResultCacheSize())).(*apiController)
+
+	pairs := []domain.BatchPair{
+		{ID: "bad", CompareRequest: domain.CompareRequest{A: "a", B: "b", Options: domain.CompareOptions{Profile: "p"}}},
+		{ID: "good", CompareRequest: domain.CompareRequest{A: "a", B: "a"}},
+	}
+	res := ac.compareBatch(context.Background(), pairs, 2, nil)
+
+	assert.Equal(t, http.StatusInternalServerError, res.Results[0].Status)
+	assert.Contains(t, res.Results[0].Error, "comparison panicked")
+	assert.Equal(t, http.StatusOK, res.Results[1].Status)
+	assert.Equal(t, 1, res.Summary.Failed)
+}
+
+func TestCompareBatch_Cancelled(t *testing.T) {
+	t.Setenv("ProfilesFile", t.TempDir()+"/profiles.json")
+	logger := zerolog.New(os.Stdout)
+	ac := newTestAPIController(&logger).(*apiController)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	pairs := []domain.BatchPair{{ID: "one"}, {ID: "two"}}
+	res := ac.compareBatch(ctx, pairs, 1, nil)
+
+	assert.Equal(t, 2, res.Summary.Failed)
+	for i, item := range res.Results {
+		assert.Equal(t, pairs[i].ID, item.ID)
+		assert.Equal(t, http.StatusServiceUnavailable, item.Status)
+		assert.Contains(t, item.Error, "context canceled")
+	}
+}
+
+func request(r *gin.Engine, method, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+	return w
+}
+
+// waitJob polls the job at location until it finishes.
+func waitJob(t *testing.T, r *gin.Engine, location string) domain.Job {
+	t.Helper()
+	var job domain.Job
+	require.Eventually(t, func() bool {
+		w := request(r, http.MethodGet, location)
+		require.Equal(t, http.StatusOK, w.Code)
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &job))
+		return job.IsFinished()
+	}, 5*time.Second, 5*time.Millisecond)
+	return job
+}
+
+func TestAPIJobs(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name   string
+		path   string
+		body   string
+		state  string
+		total  int
+		status int
+		check  func(*testing.T, []byte)
+	}{
+		{
+			name:   "compare",
+			path:   "/api/v1/jobs",
+			body:   `{"a":"one\ntwo","b":"one\nthree"}`,
+			state:  domain.JobDone,
+			total:  compareStages,
+			status: http.StatusOK,
+			check: func(t *testing.T, body []byte) {
+				var res domain.CompareResult
+				require.NoError(t, json.Unmarshal(body, &res))
+				assert.Equal(t, 1, res.Stats.LinesChanged)
+			},
+		},
+		{
+			name:   "batch",
+			path:   "/api/v1/jobs/batch",
+			body:   `{"pairs":[{"id":"x","a":"x","b":"x"},{"id":"y","a":"y","b":"z"}]}`,
+			state:  domain.JobDone,
+			total:  2,
+			status: http.StatusOK,
+			check: func(t *testing.T, body []byte) {
+				var res domain.BatchResult
+				require.NoError(t, json.Unmarshal(body, &res))
+				assert.Equal(t, domain.BatchSummary{Total: 2, Matched: 1, Different: 1, Similarity: 50}, res.Summary)
+			},
+		},
+		{
+			name:   "input fails to parse",
+			path:   "/api/v1/jobs",
+			body:   `{"a":"{bad","b":"{}","mode":"json"}`,
+			state:  domain.JobFailed,
+			status: http.StatusUnprocessableEntity,
+			check: func(t *testing.T, body []byte) {
+				assert.Contains(t, string(body), "JSON parse error for A")
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := setupTestAPIController(t)
+			w := postJSON(r, tt.path, tt.body)
+			require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+			location := w.Header().Get("Location")
+			assert.True(t, strings.HasPrefix(location, "/api/v1/jobs/"), location)
+
+			job := waitJob(t, r, location)
+			assert.Equal(t, tt.state, job.State)
+			if job.State == domain.JobDone {
+				assert.Equal(t, tt.total, job.Total)
+				assert.Equal(t, job.Total, job.Progress)
+			}
+
+			w = request(r, http.MethodGet, location+"/result")
+			assert.Equal(t, tt.status, w.Code)
+			tt.check(t, w.Body.Bytes())
+
+			w = request(r, http.MethodDelete, location)
+			assert.Equal(t, http.StatusConflict, w.Code)
+		})
+	}
+}
+
+func TestAPIJobs_Errors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := setupTestAPIController(t)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+		error  string
+	}{
+		{name: "invalid request is rejected before queuing", method: http.MethodPost, path: "/api/v1/jobs", body: `{"a":"x","b":"y","mode":"nope"}`, status: http.StatusBadRequest, error: "unknown mode"},
+		{name: "empty batch", method: http.MethodPost, path: "/api/v1/jobs/batch", body: `{"pairs":[]}`, status: http.StatusBadRequest, error: "pairs is empty"},
+		{name: "unknown job", method: http.MethodGet, path: "/api/v1/jobs/missing", status: http.StatusNotFound, error: "job not found"},
+		{name: "unknown result", method: http.MethodGet, path: "/api/v1/jobs/missing/result", status: http.StatusNotFound, error: "job not found"},
+		{name: "unknown cancel", method: http.MethodDelete, path: "/api/v1/jobs/missing", status: http.StatusNotFound, error: "job not found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.status, w.Code)
+			var apiErr domain.APIError
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &apiErr))
+			assert.Contains(t, apiErr.Error, tt.error)
+		})
+	}
+}
+
+func TestBatchWorkers(t *testing.T) {
+	limit := runtime.GOMAXPROCS(0)
+	assert.Equal(t, limit, batchWorkers(0))
+	assert.Equal(t, 1, batchWorkers(1))
+	assert.Equal(t, limit, batchWorkers(limit+10))
+}
+
+func TestAPIOpenAPI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("ProfilesFile", t.TempDir()+"/profiles.json")
+	logger := zerolog.New(os.Stdout)
+	r := gin.New()
+	Routes(r.Group(""), &logger)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/openapi.json", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "application/json")
+
+	var spec struct {
+		OpenAPI string                    `json:"openapi"`
+		Paths   map[string]map[string]any `json:"paths"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &spec))
+	assert.Equal(t, "3.0.3", spec.OpenAPI)
+
+	// Every documented operation is served
+	routes := map[string]bool{}
+	for _, route := range r.Routes() {
+		routes[route.Method+" "+route.Path] = true
+	}
+	params := strings.NewReplacer("{", ":", "}", "")
+	for path, ops := range spec.Paths {
+		for method := range ops {
+			if method == "parameters" {
+				continue
+			}
+			assert.True(t, routes[strings.ToUpper(method)+" "+params.Replace(path)], "%s %s is not routed", method, path)
+		}
+	}
+	for _, op := range []string{"POST /api/v1/compare", "POST /api/v1/compare/batch", "POST /api/v1/jobs", "POST /api/v1/jobs/batch", "GET /api/v1/jobs/{id}", "DELETE /api/v1/jobs/{id}", "GET /api/v1/jobs/{id}/result", "POST /magic/new", "GET /magic/peek", "GET /magic", "POST /sha/encode", "POST /sha/compute"} {
+		method, path, _ := strings.Cut(op, " ")
+		assert.Contains(t, spec.Paths[path], strings.ToLower(method), "%s is not documented", op)
+	}
+}
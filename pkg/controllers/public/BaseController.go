@@ -1,9 +1,15 @@
 package public
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"html/template"
+	"io"
 	"net/http"
+	"os"
+	"strconv"
 	"strings"
 
 	"github.com/gin-contrib/sessions"
@@ -15,14 +21,22 @@ import (
 )
 
 type baseController struct {
-	logger *zerolog.Logger
-	sonic  services.CacheService
+	logger   *zerolog.Logger
+	sonic    services.CacheService
+	profiles services.ProfileService
+	streams  services.StreamService
+	results  services.ResultCacheService
+	sets     services.FileSetService
 }
 
-func NewBaseController(logger *zerolog.Logger) BaseController {
+func NewBaseController(logger *zerolog.Logger, sonic services.CacheService, profiles services.ProfileService, streams services.StreamService, results services.ResultCacheService, sets services.FileSetService) BaseController {
 	return &baseController{
-		logger: logger,
-		sonic:  services.NewCacheService(),
+		logger:   logger,
+		sonic:    sonic,
+		profiles: profiles,
+		streams:  streams,
+		results:  results,
+		sets:     sets,
 	}
 }
 
@@ -34,10 +48,18 @@ type BaseController interface {
 	CreateMagicKey(ctx *gin.Context)
 	CompareUsingMagicLink(ctx *gin.Context)
 	PeekMagicKeys(ctx *gin.Context)
+
+	// Archive and multi-file comparisons
+	CompareSet(ctx *gin.Context)
+	CompareSetEntry(ctx *gin.Context)
+
+	// Files too large for memory, streamed to disk
+	CompareStream(ctx *gin.Context)
+	CompareStreamPage(ctx *gin.Context)
 }
 
 func (c *baseController) Home(ctx *gin.Context) {
-	data := domain.PageData{Mode: "auto"}
+	data := domain.PageData{Mode: "auto", Profiles: c.profiles.List()}
 	session := sessions.Default(ctx)
 	if a := session.Get("a"); a != nil {
 		data.A = a.(string)
@@ -166,109 +188,172 @@ func (c *baseController) CompareUsingMagicLink(ctx *gin.Context) {
 	`)
 }
 
+const (
+	// streamRegionsPerPage is the number of regions of a streamed comparison
+	// shown per page, each read back up to streamRegionBytes per side.
+	streamRegionsPerPage = 10
+	streamRegionBytes    = 64 << 10
+	streamContextLines   = 3
+)
+
+// modeLabels lists the supported structured modes and the name used for them
+// in error messages. "text" is always supported and needs no formatting.
+var modeLabels = map[string]string{
+	"json":     "JSON",
+	"xml":      "XML",
+	"html":     "HTML",
+	"go":       "Go",
+	"sql":      "SQL",
+	"log":      "Log",
+	"http":     "HTTP",
+	"jwt":      "JWT",
+	"yaml":     "YAML",
+	"csv":      "CSV",
+	"binary":   "Binary",
+	"image":    "Image",
+	"set":      "Set",
+	"multiset": "Multiset",
+}
+
 func (c *baseController) Compare(ctx *gin.Context) {
 	tpl, err := loadTemplates()
 	if err != nil {
 		c.logger.Fatal().Err(err).Msg("Failed to load templates")
 	}
-	action := ctx.PostForm("action") // compare | format_a | format_b | format_both
+	action := ctx.PostForm("action") // compare | format_a | format_b | format_both | save_profile | delete_profile
 
+	// Auto mode detects the format of each input once it has been read and
+	// decoded; mode is the mode actually used to compare.
+	auto := ctx.PostForm("mode") == "auto"
 	mode := ctx.PostForm("mode")
-	if mode != "json" && mode != "xml" {
+	if _, ok := modeLabels[mode]; !ok {
 		mode = "text"
 	}
 
-	ignoreWS := ctx.PostForm("ignore_ws") == "on"
-	ignoreCase := ctx.PostForm("ignore_case") == "on"
+	// Textareas. Browsers submit line breaks as CRLF, so they are folded back
+	// to LF; uploads keep their original line endings.
+	fullA := strings.ReplaceAll(ctx.PostForm("a"), "\r\n", "\n")
+	fullB := strings.ReplaceAll(ctx.PostForm("b"), "\r\n", "\n")
+	a := strings.TrimRight(fullA, "\n")
+	b := strings.TrimRight(fullB, "\n")
 
-	// Textareas
-	a := strings.TrimRight(ctx.PostForm("a"), "\r\n")
-	b := strings.TrimRight(ctx.PostForm("b"), "\r\n")
+	data := domain.PageData{
+		A:          a,
+		B:          b,
+		Mode:       mode,
+		Auto:       auto,
+		IgnoreWS:   ctx.PostForm("ignore_ws") == "on",
+		IgnoreCase: ctx.PostForm("ignore_case") == "on",
+
+		IgnoreTrailingWS: ctx.PostForm("ignore_trailing_ws") == "on",
+		IgnoreWSAmount:   ctx.PostForm("ignore_ws_amount") == "on",
+		IgnoreAllWS:      ctx.PostForm("ignore_all_ws") == "on",
+		IgnoreBlankLines: ctx.PostForm("ignore_blank_lines") == "on",
+		TabWidth:         tabWidth(ctx.PostForm("tab_width")),
+
+		IgnoreClassOrder:  ctx.PostForm("ignore_class_order") == "on",
+		IgnoreScriptStyle: ctx.PostForm("ignore_script_style") == "on",
+		IgnoreComments:    ctx.PostForm("ignore_comments") == "on",
+		JWTKey:            strings.TrimSpace(ctx.PostForm("jwt_key")),
+		Decode:            ctx.PostForm("decode"),
+		AEncoding:         ctx.PostForm("encoding_a"),
+		BEncoding:         ctx.PostForm("encoding_b"),
+		ShowWhitespace:    ctx.PostForm("show_ws") == "on",
+		ImageTolerance:    imageTolerance(ctx.PostForm("image_tolerance")),
+		Profile:           ctx.PostForm("profile"),
+		Rules:             strings.ReplaceAll(ctx.PostForm("rules"), "\r\n", "\n"),
+		Profiles:          c.profiles.List(),
+	}
+
+	// Profile actions only save or delete rules
+	if action == "save_profile" || action == "delete_profile" {
+		c.updateProfile(ctx, &data, action)
+		utils.Render(ctx, tpl, data)
+		return
+	}
+	if data.NormalizeRules, err = c.normalizeRules(data); err != nil {
+		data.Error = "Normalization rules: " + err.Error()
+		utils.Render(ctx, tpl, data)
+		return
+	}
+
+	// Sets of files or folders are paired by relative path
+	setA, err := utils.ReadGinFiles(ctx, "files_a")
+	if err != nil {
+		data.Error = "Reading files A failed: " + err.Error()
+		utils.Render(ctx, tpl, data)
+		return
+	}
+	setB, err := utils.ReadGinFiles(ctx, "files_b")
+	if err != nil {
+		data.Error = "Reading files B failed: " + err.Error()
+		utils.Render(ctx, tpl, data)
+		return
+	}
+	if len(setA) > 0 || len(setB) > 0 {
+		c.renderFileSet(ctx, tpl, data, domain.FileSet{A: setA, B: setB})
+		return
+	}
+
+	fa, _ := utils.ReadGinFile(ctx, "file_a")
+	fb, _ := utils.ReadGinFile(ctx, "file_b")
+
+	// Two archives are compared entry by entry rather than byte by byte
+	if utils.IsArchive(fa) && utils.IsArchive(fb) {
+		c.compareArchives(ctx, tpl, data, fa, fb)
+		return
+	}
 
-	// Uploaded files override textarea if present
-	if fa, _ := utils.ReadGinFile(ctx, "file_a"); fa != "" {
-		a = fa
+	// Uploaded files override textarea if present and are converted to UTF-8
+	rawA, rawB := a, b
+	if fa != "" {
+		if a, data.ADetectedEncoding, err = utils.ToUTF8(fa, data.AEncoding); err != nil {
+			data.Error = "Reading file A failed: " + err.Error()
+			utils.Render(ctx, tpl, data)
+			return
+		}
+		rawA, fullA, data.A = fa, a, a
 	}
-	if fb, _ := utils.ReadGinFile(ctx, "file_b"); fb != "" {
-		b = fb
+	if fb != "" {
+		if b, data.BDetectedEncoding, err = utils.ToUTF8(fb, data.BEncoding); err != nil {
+			data.Error = "Reading file B failed: " + err.Error()
+			utils.Render(ctx, tpl, data)
+			return
+		}
+		rawB, fullB, data.B = fb, b, b
 	}
+	data.EncodingOnly = rawA != rawB && a == b
 
 	// Pretty-print actions
 	if action == "format_a" || action == "format_b" || action == "format_both" {
-		var err error
-
-		switch mode {
-		case "json":
-			if action == "format_a" || action == "format_both" {
-				a, err = utils.PrettyJSON(a)
-				if err != nil {
-					utils.Render(ctx, tpl, domain.PageData{
-						A:          a,
-						B:          b,
-						Mode:       mode,
-						IgnoreWS:   ignoreWS,
-						IgnoreCase: ignoreCase,
-						Error:      "Pretty JSON A failed: " + err.Error(),
-					})
-					return
-				}
+		if action == "format_a" || action == "format_both" {
+			modeA := mode
+			if auto {
+				modeA = utils.SniffMode(data.A)
 			}
-			if action == "format_b" || action == "format_both" {
-				b, err = utils.PrettyJSON(b)
-				if err != nil {
-					utils.Render(ctx, tpl, domain.PageData{
-						A:          a,
-						B:          b,
-						Mode:       mode,
-						IgnoreWS:   ignoreWS,
-						IgnoreCase: ignoreCase,
-						Error:      "Pretty JSON B failed: " + err.Error(),
-					})
-					return
-				}
+			pretty, err := formatForMode(modeA, data.A, data)
+			if err != nil {
+				data.Error = "Pretty " + modeLabels[modeA] + " A failed: " + err.Error()
+				utils.Render(ctx, tpl, data)
+				return
 			}
-
-		case "xml":
-			if action == "format_a" || action == "format_both" {
-				a, err = utils.PrettyXML(a)
-				if err != nil {
-					utils.Render(ctx, tpl, domain.PageData{
-						A:          a,
-						B:          b,
-						Mode:       mode,
-						IgnoreWS:   ignoreWS,
-						IgnoreCase: ignoreCase,
-						Error:      "Pretty XML A failed: " + err.Error(),
-					})
-					return
-				}
+			data.A = pretty
+		}
+		if action == "format_b" || action == "format_both" {
+			modeB := mode
+			if auto {
+				modeB = utils.SniffMode(data.B)
 			}
-			if action == "format_b" || action == "format_both" {
-				b, err = utils.PrettyXML(b)
-				if err != nil {
-					utils.Render(ctx, tpl, domain.PageData{
-						A:          a,
-						B:          b,
-						Mode:       mode,
-						IgnoreWS:   ignoreWS,
-						IgnoreCase: ignoreCase,
-						Error:      "Pretty XML B failed: " + err.Error(),
-					})
-					return
-				}
+			pretty, err := formatForMode(modeB, data.B, data)
+			if err != nil {
+				data.Error = "Pretty " + modeLabels[modeB] + " B failed: " + err.Error()
+				utils.Render(ctx, tpl, data)
+				return
 			}
-
-		default:
-			// text mode: do nothing
+			data.B = pretty
 		}
 
-		utils.Render(ctx, tpl, domain.PageData{
-			A:          a,
-			B:          b,
-			Mode:       mode,
-			IgnoreWS:   ignoreWS,
-			IgnoreCase: ignoreCase,
-		})
+		utils.Render(ctx, tpl, data)
 		return
 	}
 
@@ -277,99 +362,726 @@ func (c *baseController) Compare(ctx *gin.Context) {
 		action = "compare"
 	}
 
-	compareA := a
-	compareB := b
+	if err := compareCached(ctx.Request.Context(), c.results, &data, mode, a, b, fullA, fullB, nil); err != nil {
+		return // the client has gone
+	}
+	utils.Render(ctx, tpl, data)
+}
+
+func (c *baseController) compareArchives(ctx *gin.Context, tpl *template.Template, data domain.PageData, fa, fb string) {
+	var set domain.FileSet
+	var err error
+	if set.A, err = utils.ReadArchive(fa); err != nil {
+		data.Error = "Reading archive A failed: " + err.Error()
+		utils.Render(ctx, tpl, data)
+		return
+	}
+	if set.B, err = utils.ReadArchive(fb); err != nil {
+		data.Error = "Reading archive B failed: " + err.Error()
+		utils.Render(ctx, tpl, data)
+		return
+	}
+	c.renderFileSet(ctx, tpl, data, set)
+}
+
+// renderFileSet stores set so each file can be opened later and renders the
+// tree of entries.
+func (c *baseController) renderFileSet(ctx *gin.Context, tpl *template.Template, data domain.PageData, set domain.FileSet) {
+	id, err := c.sets.Add(set)
+	if err != nil {
+		c.logger.Error().Err(err).Msg("Failed to store file set")
+		data.Error = "Storing files failed: " + err.Error()
+		utils.Render(ctx, tpl, data)
+		return
+	}
+
+	fillFileSet(&data, id, set)
+	utils.Render(ctx, tpl, data)
+}
+
+// CompareSet renders the entry tree of a cached archive or multi-file
+// comparison.
+func (c *baseController) CompareSet(ctx *gin.Context) {
+	id := ctx.Query("id")
+	set, ok := c.sets.Get(id)
+	if !ok {
+		ctx.Redirect(http.StatusFound, "/?error=not_found")
+		return
+	}
 
-	// If mode is json/xml, compare normalized/pretty versions for stable diffs
-	if mode == "json" {
-		var err error
-		compareA, err = utils.PrettyJSON(a)
+	tpl, err := loadTemplates()
+	if err != nil {
+		c.logger.Fatal().Err(err).Msg("Failed to load templates")
+	}
+	data := domain.PageData{Mode: "auto", Profiles: c.profiles.List()}
+	fillFileSet(&data, id, set)
+	utils.Render(ctx, tpl, data)
+}
+
+// CompareSetEntry diffs one file of a cached set, detecting its mode.
+func (c *baseController) CompareSetEntry(ctx *gin.Context) {
+	id, path := ctx.Query("id"), ctx.Query("path")
+	set, ok := c.sets.Get(id)
+	if !ok {
+		ctx.Redirect(http.StatusFound, "/?error=not_found")
+		return
+	}
+
+	tpl, err := loadTemplates()
+	if err != nil {
+		c.logger.Fatal().Err(err).Msg("Failed to load templates")
+	}
+	a, b := string(set.A[path]), string(set.B[path])
+	data := domain.PageData{A: a, B: b, Mode: "auto", Auto: true, SetID: id, SetEntry: path, Profiles: c.profiles.List()}
+	if err := compareCached(ctx.Request.Context(), c.results, &data, "text", a, b, a, b, nil); err != nil {
+		return // the client has gone
+	}
+	utils.Render(ctx, tpl, data)
+}
+
+// CompareStream streams the large_a and large_b uploads to disk, chunking
+// them as they arrive, then aligns the chunks and redirects to the first page
+// of differing regions. Neither file is held in memory.
+func (c *baseController) CompareStream(ctx *gin.Context) {
+	tpl, err := loadTemplates()
+	if err != nil {
+		c.logger.Fatal().Err(err).Msg("Failed to load templates")
+	}
+	data := domain.PageData{Mode: "auto", Profiles: c.profiles.List()}
+
+	id, err := c.streams.Create()
+	if errors.Is(err, services.ErrTooManyStreams) {
+		data.Error = "Comparing large files failed: " + err.Error() + ", try again later"
+		utils.RenderStatus(ctx, tpl, http.StatusServiceUnavailable, data)
+		return
+	}
+	if err != nil {
+		c.logger.Error().Err(err).Msg("Failed to create stream directory")
+		data.Error = "Storing files failed: " + err.Error()
+		utils.Render(ctx, tpl, data)
+		return
+	}
+	diff, err := c.spillStreams(ctx, id)
+	if err != nil {
+		c.streams.Delete(id)
+		data.Error = "Comparing large files failed: " + err.Error()
+		utils.Render(ctx, tpl, data)
+		return
+	}
+	c.streams.Save(diff)
+	ctx.Redirect(http.StatusSeeOther, "/compare/stream?id="+id)
+}
+
+// spillStreams reads the multipart body part by part, copying each large file
+// to the stream's directory, and aligns the two.
+func (c *baseController) spillStreams(ctx *gin.Context, id string) (domain.StreamDiff, error) {
+	diff := domain.StreamDiff{ID: id}
+	mr, err := ctx.Request.MultipartReader()
+	if err != nil {
+		return diff, err
+	}
+
+	var chunksA, chunksB []domain.StreamChunk
+	var gotA, gotB bool
+	for {
+		part, err := mr.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
 		if err != nil {
-			utils.Render(ctx, tpl, domain.PageData{
-				A:          a,
-				B:          b,
-				Mode:       mode,
-				IgnoreWS:   ignoreWS,
-				IgnoreCase: ignoreCase,
-				Error:      "JSON parse error for A: " + err.Error(),
-			})
-			return
+			return diff, err
+		}
+		side := strings.TrimPrefix(part.FormName(), "large_")
+		if (side != "a" && side != "b") || part.FileName() == "" {
+			part.Close()
+			continue
 		}
-		compareB, err = utils.PrettyJSON(b)
+
+		chunks, file, err := spillStream(part, c.streams.Path(id, side))
+		part.Close()
 		if err != nil {
-			utils.Render(ctx, tpl, domain.PageData{
-				A:          a,
-				B:          b,
-				Mode:       mode,
-				IgnoreWS:   ignoreWS,
-				IgnoreCase: ignoreCase,
-				Error:      "JSON parse error for B: " + err.Error(),
-			})
-			return
+			return diff, fmt.Errorf("%s: %w", strings.ToUpper(side), err)
 		}
-	} else if mode == "xml" {
-		var err error
-		compareA, err = utils.PrettyXML(a)
+		file.Name = part.FileName()
+		if side == "a" {
+			chunksA, diff.A, gotA = chunks, file, true
+		} else {
+			chunksB, diff.B, gotB = chunks, file, true
+		}
+	}
+	if !gotA || !gotB {
+		return diff, errors.New("choose a file for both A and B")
+	}
+
+	diff.Regions = utils.AlignChunks(chunksA, chunksB, diff.A, diff.B)
+	return diff, nil
+}
+
+func spillStream(r io.Reader, path string) ([]domain.StreamChunk, domain.StreamFile, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, domain.StreamFile{}, err
+	}
+	chunks, file, err := utils.ChunkStream(r, f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return chunks, file, err
+}
+
+// CompareStreamPage renders one page of the regions of a streamed comparison,
+// reading each region back from disk.
+func (c *baseController) CompareStreamPage(ctx *gin.Context) {
+	id := ctx.Query("id")
+	diff, ok := c.streams.Get(id)
+	if !ok {
+		ctx.Redirect(http.StatusFound, "/?error=not_found")
+		return
+	}
+
+	tpl, err := loadTemplates()
+	if err != nil {
+		c.logger.Fatal().Err(err).Msg("Failed to load templates")
+	}
+	data := domain.PageData{Mode: "auto", Profiles: c.profiles.List()}
+	data.ALen, data.BLen = int(diff.A.Size), int(diff.B.Size)
+	data.AHash, data.BHash = diff.A.SHA256, diff.B.SHA256
+	data.ExactMatch = diff.A.SHA256 == diff.B.SHA256
+	data.NormalizedMatch = data.ExactMatch
+
+	page, _ := strconv.Atoi(ctx.Query("page"))
+	stream := domain.StreamPage{Diff: diff, Pages: max((len(diff.Regions)+streamRegionsPerPage-1)/streamRegionsPerPage, 1)}
+	stream.Page = min(max(page, 1), stream.Pages)
+	start := (stream.Page - 1) * streamRegionsPerPage
+	for _, r := range diff.Regions[start:min(start+streamRegionsPerPage, len(diff.Regions))] {
+		view, err := c.readStreamRegion(id, r)
 		if err != nil {
-			utils.Render(ctx, tpl, domain.PageData{
-				A:          a,
-				B:          b,
-				Mode:       mode,
-				IgnoreWS:   ignoreWS,
-				IgnoreCase: ignoreCase,
-				Error:      "XML parse error for A: " + err.Error(),
-			})
+			c.logger.Error().Err(err).Msg("Failed to read stream region")
+			data.Error = "Reading files failed: " + err.Error()
+			break
+		}
+		stream.Regions = append(stream.Regions, view)
+	}
+	data.Stream = &stream
+	utils.Render(ctx, tpl, data)
+}
+
+// readStreamRegion reads up to streamRegionBytes of each side of r and diffs
+// them line by line, keeping a few lines of context around each change.
+func (c *baseController) readStreamRegion(id string, r domain.StreamRegion) (domain.StreamRegionView, error) {
+	view := domain.StreamRegionView{StreamRegion: r}
+	a, truncA, err := utils.ReadFileRange(c.streams.Path(id, "a"), r.AOffset, r.ASize, streamRegionBytes)
+	if err != nil {
+		return view, err
+	}
+	b, truncB, err := utils.ReadFileRange(c.streams.Path(id, "b"), r.BOffset, r.BSize, streamRegionBytes)
+	if err != nil {
+		return view, err
+	}
+	view.Truncated = truncA || truncB
+	rows := utils.RegionLineDiff(strings.TrimSuffix(a, "\n"), strings.TrimSuffix(b, "\n"), int(r.ALine), int(r.BLine))
+	view.Rows = utils.TrimContext(rows, streamContextLines)
+	return view, nil
+}
+
+// updateProfile saves the typed rules as the named profile, or deletes the
+// selected profile, and reports the outcome in data.
+func (c *baseController) updateProfile(ctx *gin.Context, data *domain.PageData, action string) {
+	if action == "delete_profile" {
+		if data.Profile == "" {
+			data.Error = "Pick a profile to delete."
 			return
 		}
-		compareB, err = utils.PrettyXML(b)
-		if err != nil {
-			utils.Render(ctx, tpl, domain.PageData{
-				A:          a,
-				B:          b,
-				Mode:       mode,
-				IgnoreWS:   ignoreWS,
-				IgnoreCase: ignoreCase,
-				Error:      "XML parse error for B: " + err.Error(),
-			})
+		if err := c.profiles.Delete(data.Profile); err != nil {
+			data.Error = "Deleting profile failed: " + err.Error()
 			return
 		}
+		data.Notice = fmt.Sprintf("Deleted profile %q.", data.Profile)
+		data.Profile = ""
+		data.Profiles = c.profiles.List()
+		return
+	}
+
+	name := strings.TrimSpace(ctx.PostForm("profile_name"))
+	rules, err := utils.ParseNormalizeRules(data.Rules)
+	switch {
+	case err != nil:
+		data.Error = "Normalization rules: " + err.Error()
+		return
+	case name == "":
+		data.Error = "Enter a name for the profile."
+		return
+	case len(rules) == 0:
+		data.Error = "Add at least one rule to save."
+		return
+	}
+	if err := c.profiles.Save(domain.NormalizeProfile{Name: name, Rules: rules}); err != nil {
+		c.logger.Error().Err(err).Msg("Failed to save profile")
+		data.Error = "Saving profile failed: " + err.Error()
+		return
+	}
+
+	// The saved profile now holds the rules, so select it instead
+	data.Notice = fmt.Sprintf("Saved profile %q.", name)
+	data.Profile, data.Rules = name, ""
+	data.Profiles = c.profiles.List()
+}
+
+// normalizeRules returns the rules of the selected profile followed by the
+// ad-hoc rules typed into the form.
+func (c *baseController) normalizeRules(data domain.PageData) ([]domain.NormalizeRule, error) {
+	extra, err := utils.ParseNormalizeRules(data.Rules)
+	if err != nil {
+		return nil, err
+	}
+	return resolveRules(c.profiles, data.Profile, extra)
+}
+
+// resolveRules returns the rules of the named profile, if any, followed by
+// extra.
+func resolveRules(profiles services.ProfileService, name string, extra []domain.NormalizeRule) ([]domain.NormalizeRule, error) {
+	var rules []domain.NormalizeRule
+	if name != "" {
+		profile, ok := profiles.Get(name)
+		if !ok {
+			return nil, fmt.Errorf("profile %q not found", name)
+		}
+		rules = append(rules, profile.Rules...)
+	}
+	return append(rules, extra...), nil
+}
+
+func fillFileSet(data *domain.PageData, id string, set domain.FileSet) {
+	data.SetID = id
+	data.Entries = utils.DiffFileSets(set.A, set.B)
+	data.ALen = utils.FileSetSize(set.A)
+	data.BLen = utils.FileSetSize(set.B)
+
+	data.ExactMatch = true
+	for _, e := range data.Entries {
+		if e.Status != "identical" {
+			data.ExactMatch = false
+		}
+	}
+	data.NormalizedMatch = data.ExactMatch
+}
+
+// compareCached is compareInto, reusing the result of an earlier comparison
+// of the same inputs in the same mode with the same options. A comparison
+// stopped by ctx is not cached.
+func compareCached(ctx context.Context, results services.ResultCacheService, data *domain.PageData, mode, a, b, fullA, fullB string, progress func(done, total int)) error {
+	key := resultKey(*data, mode, a, b, fullA, fullB)
+	if cached, ok := results.Get(key); ok {
+		copyResult(data, cached)
+		if progress != nil {
+			progress(compareStages, compareStages)
+		}
+		return nil
+	}
+	if err := compareInto(ctx, data, mode, a, b, fullA, fullB, progress); err != nil {
+		return err
+	}
+	var result domain.PageData
+	copyResult(&result, *data)
+	results.Add(key, result)
+	return nil
+}
+
+// resultOptions are the inputs of compareInto other than the compared text.
+// Tails hold what the untrimmed inputs add to a and b, usually line breaks.
+type resultOptions struct {
+	Mode                                          string
+	Auto                                          bool
+	IgnoreWS, IgnoreCase                          bool
+	IgnoreTrailingWS, IgnoreWSAmount, IgnoreAllWS bool
+	IgnoreBlankLines                              bool
+	TabWidth                                      int
+	IgnoreClassOrder, IgnoreScriptStyle           bool
+	IgnoreComments                                bool
+	JWTKey                                        string
+	Decode                                        string
+	ImageTolerance                                int
+	ShowWhitespace                                bool
+	NormalizeRules                                []domain.NormalizeRule
+	ATail, BTail                                  string
+}
+
+// resultKey identifies a comparison by the SHA-256 of each input, the mode
+// and the options that change its result.
+func resultKey(data domain.PageData, mode, a, b, fullA, fullB string) string {
+	opts, _ := json.Marshal(resultOptions{
+		Mode:              mode,
+		Auto:              data.Auto,
+		IgnoreWS:          data.IgnoreWS,
+		IgnoreCase:        data.IgnoreCase,
+		IgnoreTrailingWS:  data.IgnoreTrailingWS,
+		IgnoreWSAmount:    data.IgnoreWSAmount,
+		IgnoreAllWS:       data.IgnoreAllWS,
+		IgnoreBlankLines:  data.IgnoreBlankLines,
+		TabWidth:          data.TabWidth,
+		IgnoreClassOrder:  data.IgnoreClassOrder,
+		IgnoreScriptStyle: data.IgnoreScriptStyle,
+		IgnoreComments:    data.IgnoreComments,
+		JWTKey:            data.JWTKey,
+		Decode:            data.Decode,
+		ImageTolerance:    data.ImageTolerance,
+		ShowWhitespace:    data.ShowWhitespace,
+		NormalizeRules:    data.NormalizeRules,
+		ATail:             inputTail(fullA, a),
+		BTail:             inputTail(fullB, b),
+	})
+	return utils.Sha256Hex(a) + ":" + utils.Sha256Hex(b) + ":" + utils.Sha256Hex(string(opts))
+}
+
+// inputTail returns what full adds after s, or the hash of full when it does
+// not start with s.
+func inputTail(full, s string) string {
+	if tail, ok := strings.CutPrefix(full, s); ok {
+		return tail
+	}
+	return utils.Sha256Hex(full)
+}
+
+// copyResult copies the fields set by compareInto from src to dst.
+func copyResult(dst *domain.PageData, src domain.PageData) {
+	dst.Mode = src.Mode
+	dst.ADetected, dst.BDetected, dst.DetectWarning = src.ADetected, src.BDetected, src.DetectWarning
+	dst.ADecodeChain, dst.BDecodeChain = src.ADecodeChain, src.BDecodeChain
+	dst.RuleMatchesA, dst.RuleMatchesB = src.RuleMatchesA, src.RuleMatchesB
+	dst.CharIssues = src.CharIssues
+	dst.ExactMatch, dst.NormalizedMatch = src.ExactMatch, src.NormalizedMatch
+	dst.ALen, dst.BLen = src.ALen, src.BLen
+	dst.AHash, dst.BHash = src.AHash, src.BHash
+	dst.DiffBytes, dst.FirstDiffOffset = src.DiffBytes, src.FirstDiffOffset
+	dst.Image = src.Image
+	dst.SetStats = src.SetStats
+	dst.Stats = src.Stats
+	dst.LineDiff = src.LineDiff
+	dst.Changes = src.Changes
+	dst.Error = src.Error
+}
+
+// compareStages is the number of stages compareInto reports progress in.
+const compareStages = 5
+
+// compareInto runs the comparison of a and b in mode and stores the results,
+// or the first failure in data.Error. fullA and fullB are the untrimmed
+// inputs used for the hidden character report. progress, when set, is called
+// as each stage finishes; once ctx is done the comparison stops between
+// stages with its error.
+func compareInto(ctx context.Context, data *domain.PageData, mode, a, b, fullA, fullB string, progress func(done, total int)) error {
+	stage := 0
+	next := func() error {
+		if stage++; progress != nil {
+			progress(stage, compareStages)
+		}
+		return ctx.Err()
+	}
+	// Modes that skip stages finish all of them at once
+	defer func() {
+		if progress != nil && stage < compareStages && ctx.Err() == nil {
+			progress(compareStages, compareStages)
+		}
+	}()
+	var err error
+
+	// Peel off layered encodings (base64, gzip, ...) before comparing
+	if data.Decode != "" {
+		if a, data.ADecodeChain, err = utils.DecodeLayers(a, data.Decode); err != nil {
+			data.Error = "Decode A failed: " + err.Error()
+			return nil
+		}
+		if b, data.BDecodeChain, err = utils.DecodeLayers(b, data.Decode); err != nil {
+			data.Error = "Decode B failed: " + err.Error()
+			return nil
+		}
+	}
+
+	if data.Auto {
+		mode = detectMode(data, a, b)
+		data.Mode = mode
+	}
+	if err := next(); err != nil {
+		return err
+	}
+
+	if mode == "image" {
+		compareImages(data, a, b)
+		return nil
+	}
+
+	// Regex rules rewrite both inputs, but exact matches are still judged on
+	// the inputs as given
+	origA, origB := a, b
+	if len(data.NormalizeRules) > 0 && mode != "binary" {
+		if a, data.RuleMatchesA, err = utils.ApplyNormalizeRules(a, data.NormalizeRules); err != nil {
+			data.Error = "Normalization rules: " + err.Error()
+			return nil
+		}
+		if b, data.RuleMatchesB, err = utils.ApplyNormalizeRules(b, data.NormalizeRules); err != nil {
+			data.Error = "Normalization rules: " + err.Error()
+			return nil
+		}
+	}
+
+	// Report line endings and invisible characters from the untrimmed inputs
+	if mode != "binary" {
+		data.CharIssues = utils.InspectChars(fullA, fullB)
+	}
+	if err := next(); err != nil {
+		return err
+	}
+
+	if mode == "set" || mode == "multiset" {
+		compareLineSets(data, mode, a, b)
+		data.ExactMatch = origA == origB
+		return nil
+	}
+
+	// Structured modes compare normalized/pretty versions for stable diffs
+	compareA, err := formatForMode(mode, a, *data)
+	if err != nil {
+		data.Error = modeLabels[mode] + " parse error for A: " + err.Error()
+		return nil
+	}
+	compareB, err := formatForMode(mode, b, *data)
+	if err != nil {
+		data.Error = modeLabels[mode] + " parse error for B: " + err.Error()
+		return nil
+	}
+	if err := next(); err != nil {
+		return err
+	}
+
+	data.Changes, err = changesForMode(mode, a, b, *data)
+	if err != nil {
+		data.Error = modeLabels[mode] + " parse error: " + err.Error()
+		return nil
+	}
+	if err := next(); err != nil {
+		return err
 	}
 
 	exact := compareA == compareB
+	if exact && data.RuleMatchesA+data.RuleMatchesB > 0 {
+		exact = sameFormatted(mode, origA, origB, *data)
+	}
 
 	na := compareA
 	nb := compareB
-	if ignoreWS {
+	if mode == "log" {
+		na = utils.MaskLog(na)
+		nb = utils.MaskLog(nb)
+	}
+	// Whitespace and case options do not apply to raw bytes
+	ws := whitespaceOptions(*data)
+	if ws.Active() && mode != "binary" {
+		na = utils.NormalizeLines(na, ws)
+		nb = utils.NormalizeLines(nb, ws)
+	}
+	if data.IgnoreWS && mode != "binary" {
 		na = utils.NormalizeWhitespace(na)
 		nb = utils.NormalizeWhitespace(nb)
 	}
-	if ignoreCase {
+	if data.IgnoreCase && mode != "binary" {
 		na = strings.ToLower(na)
 		nb = strings.ToLower(nb)
 	}
 
-	normalized := na == nb
+	data.ExactMatch = exact
+	data.NormalizedMatch = na == nb
 
-	data := domain.PageData{
-		A:          a,
-		B:          b,
-		Mode:       mode,
-		IgnoreWS:   ignoreWS,
-		IgnoreCase: ignoreCase,
+	data.ALen = len(compareA)
+	data.BLen = len(compareB)
 
-		ExactMatch:      exact,
-		NormalizedMatch: normalized,
+	data.AHash = utils.Sha256Hex(compareA)
+	data.BHash = utils.Sha256Hex(compareB)
+
+	switch {
+	case mode == "binary":
+		data.LineDiff, data.DiffBytes, data.FirstDiffOffset = utils.HexDumpDiff(compareA, compareB)
+	case mode == "log":
+		data.LineDiff = utils.LogLineDiff(compareA, compareB)
+	case data.ShowWhitespace:
+		data.LineDiff = utils.VisibleLineDiff(compareA, compareB)
+	case ws.Active():
+		data.LineDiff = utils.WhitespaceLineDiff(compareA, compareB, ws)
+	default:
+		data.LineDiff = utils.BasicLineDiffWithHighlight(compareA, compareB)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 
-		ALen: len(compareA),
-		BLen: len(compareB),
+	var stats domain.DiffStats
+	if mode == "binary" {
+		stats = utils.DiffStatsForBytes(compareA, compareB, data.DiffBytes)
+	} else {
+		stats = utils.ComputeDiffStats(compareA, compareB, data.LineDiff, data.Changes)
+	}
+	data.Stats = &stats
+	return next()
+}
 
-		AHash: utils.Sha256Hex(compareA),
-		BHash: utils.Sha256Hex(compareB),
+// sameFormatted reports whether a and b are equal once pretty-printed for
+// mode.
+func sameFormatted(mode, a, b string, data domain.PageData) bool {
+	fa, errA := formatForMode(mode, a, data)
+	fb, errB := formatForMode(mode, b, data)
+	return errA == nil && errB == nil && fa == fb
+}
 
-		LineDiff: utils.BasicLineDiffWithHighlight(compareA, compareB),
+// compareImages diffs two images pixel by pixel. They match exactly when the
+// files are identical and after normalization when no pixel differs by more
+// than the tolerance, e.g. the same screenshot saved as PNG and GIF.
+func compareImages(data *domain.PageData, a, b string) {
+	diff, err := utils.DiffImages(a, b, data.ImageTolerance)
+	if err != nil {
+		data.Error = "Image parse error for " + err.Error()
+		return
 	}
+	data.Image = diff
+	data.ExactMatch = a == b
+	data.NormalizedMatch = diff.ChangedPixels == 0
+	data.ALen, data.BLen = len(a), len(b)
+	data.AHash, data.BHash = utils.Sha256Hex(a), utils.Sha256Hex(b)
+}
 
-	utils.Render(ctx, tpl, data)
+// compareLineSets compares the lines of a and b ignoring order. The ignore
+// options apply to each line, so the normalized match means the inputs hold
+// the same lines (with the same counts in multiset mode).
+func compareLineSets(data *domain.PageData, mode, a, b string) {
+	changes, stats := utils.DiffLineSets(a, b, utils.SetOptions{
+		Multiset:   mode == "multiset",
+		IgnoreWS:   data.IgnoreWS,
+		IgnoreCase: data.IgnoreCase,
+	})
+	data.Changes = changes
+	data.SetStats = &stats
+	data.ExactMatch = a == b
+	data.NormalizedMatch = len(changes) == 0
+	data.ALen, data.BLen = len(a), len(b)
+	data.AHash, data.BHash = utils.Sha256Hex(a), utils.Sha256Hex(b)
+}
+
+// imageTolerance parses the per-channel tolerance, clamped to 0-255.
+func imageTolerance(s string) int {
+	n, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil {
+		return 0
+	}
+	return min(max(n, 0), 255)
+}
+
+// tabWidth parses the tab expansion width, 0 (off) to 16.
+func tabWidth(s string) int {
+	n, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil {
+		return 0
+	}
+	return min(max(n, 0), 16)
+}
+
+// detectMode sniffs the format of a and b for auto mode and records what was
+// found. When the two disagree the inputs are compared as text with a
+// warning, except that binary (or an image against anything else) is
+// compared byte by byte since it cannot be line diffed.
+func detectMode(data *domain.PageData, a, b string) string {
+	ma, mb := utils.SniffMode(a), utils.SniffMode(b)
+	data.ADetected, data.BDetected = modeLabel(ma), modeLabel(mb)
+
+	switch {
+	case ma == mb:
+		return ma
+	case strings.TrimSpace(a) == "":
+		return mb
+	case strings.TrimSpace(b) == "":
+		return ma
+	case ma == "binary" || mb == "binary" || ma == "image" || mb == "image":
+		return "binary"
+	}
+	data.DetectWarning = fmt.Sprintf("A looks like %s but B looks like %s, so they were compared as text.", data.ADetected, data.BDetected)
+	return "text"
+}
+
+func modeLabel(mode string) string {
+	if label, ok := modeLabels[mode]; ok {
+		return label
+	}
+	return "Text"
+}
+
+// formatForMode returns the pretty-printed form of s for the given mode. Text
+// mode returns s unchanged.
+func formatForMode(mode, s string, data domain.PageData) (string, error) {
+	switch mode {
+	case "json":
+		return utils.PrettyJSON(s)
+	case "xml":
+		return utils.PrettyXML(s)
+	case "html":
+		return utils.PrettyHTML(s, htmlOptions(data))
+	case "go":
+		return utils.PrettyGo(s, goOptions(data))
+	case "sql":
+		return utils.PrettySQL(s)
+	case "http":
+		return utils.PrettyHTTP(s)
+	case "jwt":
+		return utils.PrettyJWT(s, utils.JWTOptions{Key: data.JWTKey})
+	case "yaml":
+		return utils.PrettyYAML(s)
+	case "csv":
+		return utils.PrettyCSV(s)
+	default:
+		return s, nil
+	}
+}
+
+// changesForMode returns the structural changes between a and b for modes
+// that report them, and nil for line-only modes.
+func changesForMode(mode, a, b string, data domain.PageData) ([]domain.PathChange, error) {
+	switch mode {
+	case "json":
+		return utils.DiffJSON(a, b)
+	case "html":
+		return utils.DiffHTML(a, b, htmlOptions(data))
+	case "go":
+		return utils.DiffGo(a, b, goOptions(data))
+	case "sql":
+		return utils.DiffSQL(a, b)
+	case "http":
+		return utils.DiffHTTP(a, b)
+	case "jwt":
+		return utils.DiffJWT(a, b, utils.JWTOptions{Key: data.JWTKey})
+	case "yaml":
+		return utils.DiffYAML(a, b)
+	case "csv":
+		return utils.DiffCSV(a, b)
+	default:
+		return nil, nil
+	}
+}
+
+func htmlOptions(data domain.PageData) utils.HTMLOptions {
+	return utils.HTMLOptions{
+		IgnoreClassOrder:  data.IgnoreClassOrder,
+		IgnoreScriptStyle: data.IgnoreScriptStyle,
+	}
+}
+
+func whitespaceOptions(data domain.PageData) utils.WhitespaceOptions {
+	return utils.WhitespaceOptions{
+		TabWidth:         data.TabWidth,
+		IgnoreTrailing:   data.IgnoreTrailingWS,
+		IgnoreAmount:     data.IgnoreWSAmount,
+		IgnoreAll:        data.IgnoreAllWS,
+		IgnoreBlankLines: data.IgnoreBlankLines,
+	}
+}
+
+func goOptions(data domain.PageData) utils.GoOptions {
+	return utils.GoOptions{IgnoreComments: data.IgnoreComments}
 }
 
 func loadTemplates() (*template.Template, error) {
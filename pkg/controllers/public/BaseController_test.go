@@ -1,17 +1,37 @@
 package public
 
 import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
 	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"os"
+	"regexp"
 	"strings"
 	"testing"
+	"unicode/utf16"
 
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-contrib/sessions/cookie"
 	"github.com/gin-gonic/gin"
+	"github.com/jroden2/holmes-go/pkg/domain"
+	"github.com/jroden2/holmes-go/pkg/services"
 	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -24,7 +44,12 @@ func setupTestController() (BaseController, *gin.Engine) {
 	}
 
 	logger := zerolog.New(os.Stdout)
-	bc := NewBaseController(&logger)
+	bc := NewBaseController(&logger,
+		services.NewCacheService(),
+		services.NewProfileService(&logger, services.DefaultProfilesPath()),
+		services.NewStreamService(&logger, services.DefaultStreamDir()),
+		services.NewResultCacheService(&logger, services.DefaultResultCacheSize()),
+		services.NewFileSetService(&logger, services.DefaultFileSetSize()))
 
 	r := gin.New()
 	store := cookie.NewStore([]byte("secret"))
@@ -307,6 +332,1713 @@ func TestCompare_XMLMode(t *testing.T) {
 	}
 }
 
+func TestCompare_HTMLMode(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name              string
+		a                 string
+		b                 string
+		ignoreClassOrder  bool
+		ignoreScriptStyle bool
+		expectedExact     bool
+		expectedPaths     []string
+	}{
+		{
+			name:          "whitespace and attribute order ignored",
+			a:             `<div id="main" title="t"><p>Hello   world</p></div>`,
+			b:             "<div title=\"t\" id=\"main\">\n  <p>Hello world</p>\n</div>",
+			expectedExact: true,
+		},
+		{
+			name:          "text change reported by path",
+			a:             `<div id="main"><p>one</p><p>two</p></div>`,
+			b:             `<div id="main"><p>one</p><p>three</p></div>`,
+			expectedExact: false,
+			expectedPaths: []string{"div#main &gt; p:nth-of-type(2)::text"},
+		},
+		{
+			name:          "attribute change reported by path",
+			a:             `<ul><li class="x">1</li></ul>`,
+			b:             `<ul><li class="y">1</li></ul>`,
+			expectedExact: false,
+			expectedPaths: []string{"ul &gt; li[class]"},
+		},
+		{
+			name:          "class order - ignore off",
+			a:             `<p class="a b">x</p>`,
+			b:             `<p class="b a">x</p>`,
+			expectedExact: false,
+			expectedPaths: []string{"p[class]"},
+		},
+		{
+			name:             "class order - ignore on",
+			a:                `<p class="a b">x</p>`,
+			b:                `<p class="b a">x</p>`,
+			ignoreClassOrder: true,
+			expectedExact:    true,
+		},
+		{
+			name:              "inline script and style - ignore on",
+			a:                 `<div><script>var a = 1;</script><p style="color:red">x</p></div>`,
+			b:                 `<div><style>p {}</style><p>x</p></div>`,
+			ignoreScriptStyle: true,
+			expectedExact:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			controller, r := setupTestController()
+			r.POST("/compare", controller.Compare)
+
+			w := httptest.NewRecorder()
+
+			form := url.Values{}
+			form.Add("a", tt.a)
+			form.Add("b", tt.b)
+			form.Add("mode", "html")
+			if tt.ignoreClassOrder {
+				form.Add("ignore_class_order", "on")
+			}
+			if tt.ignoreScriptStyle {
+				form.Add("ignore_script_style", "on")
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/compare", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+
+			body := w.Body.String()
+			if tt.expectedExact {
+				assert.Contains(t, body, "YES")
+				assert.NotContains(t, body, "Structural changes")
+			} else {
+				assert.Contains(t, body, "Structural changes")
+			}
+			for _, p := range tt.expectedPaths {
+				assert.Contains(t, body, p)
+			}
+		})
+	}
+}
+
+func TestCompare_GoMode(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name           string
+		a              string
+		b              string
+		ignoreComments bool
+		expectedPaths  []string
+		expectChanges  bool
+		expectError    bool
+	}{
+		{
+			name:          "formatting only",
+			a:             "package x\n\ntype T struct{ A int }\nfunc F() { return }",
+			b:             "package x\n\ntype T struct {\n\tA int\n}\n\nfunc F() {\n\treturn\n}\n",
+			expectChanges: false,
+		},
+		{
+			name:          "signature and body changes",
+			a:             "package x\n\nfunc F(a int) {}\n\nfunc (t *T) M() {}\n",
+			b:             "package x\n\nfunc F(a, b int) {}\n\nfunc (t *T) M() { println() }\n",
+			expectChanges: true,
+			expectedPaths: []string{"func F (signature)", "method (*T).M (body)"},
+		},
+		{
+			name:          "added and removed declarations",
+			a:             "package x\n\nconst A = 1\n",
+			b:             "package x\n\nconst B = 1\n",
+			expectChanges: true,
+			expectedPaths: []string{"const A", "const B"},
+		},
+		{
+			name:          "snippet without package clause",
+			a:             "func F() int { return 1 }",
+			b:             "func F() int { return 2 }",
+			expectChanges: true,
+			expectedPaths: []string{"func F (body)"},
+		},
+		{
+			name:          "comment change - ignore off",
+			a:             "package x\n\n// F does a\nfunc F() {}\n",
+			b:             "package x\n\n// F does b\nfunc F() {}\n",
+			expectChanges: true,
+			expectedPaths: []string{"func F (doc)"},
+		},
+		{
+			name:           "comment change - ignore on",
+			a:              "package x\n\n// F does a\nfunc F() {}\n",
+			b:              "package x\n\n// F does b\nfunc F() {}\n",
+			ignoreComments: true,
+			expectChanges:  false,
+		},
+		{
+			name:          "repeated keys matched by position",
+			a:             "package x\n\nvar _ I = (*T)(nil)\nvar _ J = (*T)(nil)\n\nfunc init() { a() }\nfunc init() { b() }\n",
+			b:             "package x\n\nvar _ I = (*T)(nil)\nvar _ J = (*T)(nil)\n\nfunc init() { a() }\nfunc init() { c() }\n",
+			expectChanges: true,
+			expectedPaths: []string{"func init#1 (body)"},
+		},
+		{
+			name:          "repeated keys unchanged",
+			a:             "package x\n\nvar _ I = (*T)(nil)\nvar _ J = (*T)(nil)\n\nfunc init() { a() }\nfunc init() { b() }\n",
+			b:             "package x\n\nvar _ I = (*T)(nil)\nvar _ J = (*T)(nil)\n\nfunc init() { a() }\nfunc init() { b() }\n",
+			expectChanges: false,
+		},
+		{
+			name:          "repeated key removed",
+			a:             "package x\n\nfunc init() { a() }\nfunc init() { b() }\n",
+			b:             "package x\n\nfunc init() { a() }\n",
+			expectChanges: true,
+			expectedPaths: []string{"func init#1"},
+		},
+		{
+			name:        "invalid go in a",
+			a:           "package x\n\nfunc {",
+			b:           "package x\n",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			controller, r := setupTestController()
+			r.POST("/compare", controller.Compare)
+
+			w := httptest.NewRecorder()
+
+			form := url.Values{}
+			form.Add("a", tt.a)
+			form.Add("b", tt.b)
+			form.Add("mode", "go")
+			if tt.ignoreComments {
+				form.Add("ignore_comments", "on")
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/compare", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+
+			body := w.Body.String()
+			if tt.expectError {
+				assert.Contains(t, body, "Go parse error for A")
+				return
+			}
+			if tt.expectChanges {
+				assert.Contains(t, body, "Structural changes")
+			} else {
+				assert.NotContains(t, body, "Structural changes")
+			}
+			for _, p := range tt.expectedPaths {
+				assert.Contains(t, body, p)
+			}
+		})
+	}
+}
+
+func TestCompare_SQLMode(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name          string
+		a             string
+		b             string
+		expectedExact bool
+		expectedPaths []string
+		expectError   bool
+
+		expectedNoChanges bool
+		excludedPaths     []string
+	}{
+		{
+			name:          "keyword case and whitespace ignored",
+			a:             "select id,\n  name from users -- all users\nwhere id = 1",
+			b:             "SELECT id, name FROM users WHERE id = 1;",
+			expectedExact: true,
+		},
+		{
+			name:          "column type change",
+			a:             "CREATE TABLE users (id INT PRIMARY KEY, email VARCHAR(255) NOT NULL);",
+			b:             "create table users (id int primary key, email varchar(320) not null);",
+			expectedPaths: []string{"table users &gt; column email"},
+		},
+		{
+			name:          "altered table and new index",
+			a:             "CREATE TABLE users (id INT);",
+			b:             "CREATE TABLE users (id INT);\nALTER TABLE users ADD COLUMN name TEXT;\nCREATE UNIQUE INDEX idx_name ON users (name);",
+			expectedPaths: []string{"table users &gt; column name", "table users &gt; index idx_name"},
+		},
+		{
+			name:          "dropped table and changed constraint",
+			a:             "CREATE TABLE a (id INT, CONSTRAINT pk_a PRIMARY KEY (id));\nCREATE TABLE b (id INT);",
+			b:             "CREATE TABLE a (id INT, CONSTRAINT pk_a PRIMARY KEY (id, id2));\nDROP TABLE IF EXISTS b;",
+			expectedPaths: []string{"table a &gt; constraint pk_a", "table b"},
+		},
+		{
+			name:              "alter column amends the definition",
+			a:                 "CREATE TABLE t (n INT NOT NULL DEFAULT 1, m TEXT);",
+			b:                 "CREATE TABLE t (n INT DEFAULT 0, m TEXT NOT NULL);\nALTER TABLE t ALTER COLUMN n SET NOT NULL;\nALTER TABLE t ALTER COLUMN n SET DEFAULT 1;\nALTER TABLE t ALTER COLUMN m DROP NOT NULL;",
+			expectedNoChanges: true,
+		},
+		{
+			name:          "alter column type keeps attributes",
+			a:             "CREATE TABLE t (n INT NOT NULL);\nALTER TABLE t ALTER COLUMN n TYPE BIGINT USING n::bigint;",
+			b:             "CREATE TABLE t (n BIGINT);",
+			expectedPaths: []string{"table t &gt; column n", "BIGINT NOT NULL"},
+		},
+		{
+			name:              "several actions in one alter",
+			a:                 "CREATE TABLE t (id INT, a INT, b TEXT);",
+			b:                 "CREATE TABLE t (id INT);\nALTER TABLE t ADD COLUMN a INT, ADD COLUMN b TEXT;",
+			expectedNoChanges: true,
+		},
+		{
+			name:          "columns named key and index",
+			a:             "CREATE TABLE kv (key TEXT, index INT, value TEXT);",
+			b:             "CREATE TABLE kv (key VARCHAR(20), index INT, value TEXT);",
+			expectedPaths: []string{"table kv &gt; column key"},
+			excludedPaths: []string{"index varchar", "index text"},
+		},
+		{
+			name:          "inline indexes",
+			a:             "CREATE TABLE t (id INT, KEY idx_id (id), INDEX (id));",
+			b:             "CREATE TABLE t (id INT, id2 INT, KEY idx_id (id, id2));",
+			expectedPaths: []string{"table t &gt; index idx_id", "table t &gt; index (id)"},
+		},
+		{
+			name:        "unterminated string",
+			a:           "SELECT 'oops",
+			b:           "SELECT 1",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			controller, r := setupTestController()
+			r.POST("/compare", controller.Compare)
+
+			w := httptest.NewRecorder()
+
+			form := url.Values{}
+			form.Add("a", tt.a)
+			form.Add("b", tt.b)
+			form.Add("mode", "sql")
+
+			req := httptest.NewRequest(http.MethodPost, "/compare", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+
+			body := w.Body.String()
+			if tt.expectError {
+				assert.Contains(t, body, "SQL parse error for A")
+				return
+			}
+			if tt.expectedExact {
+				assert.NotContains(t, body, "Structural changes")
+				assert.NotContains(t, body, `class="changed"`)
+			}
+			if tt.expectedNoChanges {
+				assert.NotContains(t, body, "Structural changes")
+			}
+			for _, p := range tt.expectedPaths {
+				assert.Contains(t, body, p)
+			}
+			for _, p := range tt.excludedPaths {
+				assert.NotContains(t, body, p)
+			}
+		})
+	}
+}
+
+func TestCompare_LogMode(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name            string
+		a               string
+		b               string
+		expectedNormal  bool
+		expectedMarked  []string
+		expectedChanged bool
+	}{
+		{
+			name:           "only volatile tokens differ",
+			a:              "2026-01-26T10:37:54Z INFO id=550e8400-e29b-41d4-a716-446655440000 from 10.0.0.1 took 12ms",
+			b:              "2026-01-27T08:00:01.5Z INFO id=123e4567-e89b-12d3-a456-426614174000 from 10.0.0.2 took 3.2s",
+			expectedNormal: true,
+			expectedMarked: []string{"2026-01-26T10:37:54Z", "10.0.0.1", "12ms"},
+		},
+		{
+			name:           "epoch and hex ids masked",
+			a:              "ts=1706265474 trace=4bf92f3577b34da6 ok",
+			b:              "ts=1706265999 trace=00f067aa0ba902b7 ok",
+			expectedNormal: true,
+		},
+		{
+			name:            "real change still reported",
+			a:               "2026-01-26T10:37:54Z status=200",
+			b:               "2026-01-26T10:37:55Z status=500",
+			expectedNormal:  false,
+			expectedChanged: true,
+			expectedMarked:  []string{"status=<mark>5</mark>00"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			controller, r := setupTestController()
+			r.POST("/compare", controller.Compare)
+
+			w := httptest.NewRecorder()
+
+			form := url.Values{}
+			form.Add("a", tt.a)
+			form.Add("b", tt.b)
+			form.Add("mode", "log")
+
+			req := httptest.NewRequest(http.MethodPost, "/compare", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+
+			body := w.Body.String()
+			if tt.expectedNormal {
+				assert.Contains(t, body, "YES")
+			}
+			if tt.expectedChanged {
+				assert.Contains(t, body, `class="changed"`)
+			} else {
+				assert.NotContains(t, body, `class="changed"`)
+			}
+			for _, m := range tt.expectedMarked {
+				assert.Contains(t, body, m)
+			}
+		})
+	}
+}
+
+func TestCompare_HTTPMode(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name          string
+		a             string
+		b             string
+		expectedExact bool
+		expectedPaths []string
+		expectError   bool
+	}{
+		{
+			name:          "header case and order ignored, json body normalized",
+			a:             "HTTP/1.1 200 OK\r\ncontent-type: application/json\r\nX-Trace: 1\r\n\r\n{\"a\":1}",
+			b:             "HTTP/1.1 200 OK\nX-TRACE: 1\nContent-Type: application/json\n\n{\n  \"a\": 1\n}",
+			expectedExact: true,
+		},
+		{
+			name:          "repeated header values compared as a multiset",
+			a:             "GET / HTTP/1.1\nVia: a\nVia: b",
+			b:             "GET / HTTP/1.1\nvia: b\nvia: a",
+			expectedExact: true,
+		},
+		{
+			name:          "request line, header and body changes",
+			a:             "POST /v1/items HTTP/1.1\nHost: a.example\nContent-Type: application/json\n\n{\"id\":1}",
+			b:             "POST /v2/items HTTP/1.1\nHost: b.example\nX-New: 1\nContent-Type: application/json\n\n{\"id\":2}",
+			expectedPaths: []string{"start line", "header Host", "header X-New", "body (json)"},
+		},
+		{
+			name:        "not an http message",
+			a:           "hello world",
+			b:           "HTTP/1.1 200 OK",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			controller, r := setupTestController()
+			r.POST("/compare", controller.Compare)
+
+			w := httptest.NewRecorder()
+
+			form := url.Values{}
+			form.Add("a", tt.a)
+			form.Add("b", tt.b)
+			form.Add("mode", "http")
+
+			req := httptest.NewRequest(http.MethodPost, "/compare", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+
+			body := w.Body.String()
+			if tt.expectError {
+				assert.Contains(t, body, "HTTP parse error for A")
+				return
+			}
+			if tt.expectedExact {
+				assert.NotContains(t, body, "Structural changes")
+				assert.NotContains(t, body, `class="changed"`)
+			}
+			for _, p := range tt.expectedPaths {
+				assert.Contains(t, body, p)
+			}
+		})
+	}
+}
+
+func signTestJWT(header, claims, secret string) string {
+	enc := base64.RawURLEncoding
+	signed := enc.EncodeToString([]byte(header)) + "." + enc.EncodeToString([]byte(claims))
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(signed))
+	return signed + "." + enc.EncodeToString(mac.Sum(nil))
+}
+
+func TestCompare_JWTMode(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	header := `{"alg":"HS256","typ":"JWT"}`
+
+	tests := []struct {
+		name          string
+		a             string
+		b             string
+		key           string
+		expectedPaths []string
+		expectedText  []string
+		expectError   bool
+	}{
+		{
+			name:          "claim changes reported by path",
+			a:             signTestJWT(header, `{"sub":"alice","roles":["admin"],"exp":1700000000}`, "s3cret"),
+			b:             signTestJWT(header, `{"sub":"bob","roles":["admin","dev"],"exp":1800000000}`, "s3cret"),
+			expectedPaths: []string{"claims.sub", "claims.roles[1]", "claims.exp"},
+			expectedText:  []string{"2023-11-14T22:13:20Z"},
+		},
+		{
+			name:         "signatures verified with hmac secret",
+			a:            signTestJWT(header, `{"sub":"alice"}`, "s3cret"),
+			b:            signTestJWT(header, `{"sub":"alice"}`, "other"),
+			key:          "s3cret",
+			expectedText: []string{"valid (HS256)", "invalid (HS256): signature mismatch"},
+		},
+		{
+			name:         "unsupported alg is not verified",
+			a:            signTestJWT(`{"alg":"HS1","typ":"JWT"}`, `{"sub":"alice"}`, "s3cret"),
+			b:            signTestJWT(`{"alg":"HS999","typ":"JWT"}`, `{"sub":"alice"}`, "s3cret"),
+			key:          "s3cret",
+			expectedText: []string{"invalid (HS1): unsupported alg", "invalid (HS999): unsupported alg"},
+		},
+		{
+			name:        "not a jwt",
+			a:           "not-a-token",
+			b:           signTestJWT(header, `{}`, "s3cret"),
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			controller, r := setupTestController()
+			r.POST("/compare", controller.Compare)
+
+			w := httptest.NewRecorder()
+
+			form := url.Values{}
+			form.Add("a", tt.a)
+			form.Add("b", tt.b)
+			form.Add("mode", "jwt")
+			form.Add("jwt_key", tt.key)
+
+			req := httptest.NewRequest(http.MethodPost, "/compare", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+
+			body := w.Body.String()
+			if tt.expectError {
+				assert.Contains(t, body, "JWT parse error for A")
+				return
+			}
+			for _, p := range append(tt.expectedPaths, tt.expectedText...) {
+				assert.Contains(t, body, p)
+			}
+		})
+	}
+}
+
+func TestCompare_Decode(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write([]byte(`{"user":"alice","id":7}`))
+	zw.Close()
+	gzipped := base64.StdEncoding.EncodeToString(gz.Bytes())
+
+	// Compresses to a few KB but decompresses past the 16MB limit
+	var bomb bytes.Buffer
+	zw = gzip.NewWriter(&bomb)
+	zw.Write(make([]byte, 17<<20))
+	zw.Close()
+	bombed := base64.StdEncoding.EncodeToString(bomb.Bytes())
+
+	tests := []struct {
+		name         string
+		a            string
+		b            string
+		mode         string
+		decode       string
+		expectedText []string
+		absentText   []string
+		expectError  string
+	}{
+		{
+			name:         "auto peels base64 and gzip then compares as json",
+			a:            gzipped,
+			b:            `{"id":7,"user":"alice"}`,
+			mode:         "auto",
+			decode:       "auto",
+			expectedText: []string{"Decoded A:", "base64 → gzip", "Detected: A <strong>JSON</strong>"},
+		},
+		{
+			name:         "explicit chain",
+			a:            "68656c6c6f",
+			b:            "776f726c64",
+			mode:         "text",
+			decode:       "hex",
+			expectedText: []string{"Decoded A:", "Decoded B:", "hello", "world"},
+		},
+		{
+			name:         "plain text left alone in auto",
+			a:            "just some words",
+			b:            "just some words",
+			mode:         "text",
+			decode:       "auto",
+			expectedText: []string{"just some words"},
+			absentText:   []string{"Decoded A:"},
+		},
+		{
+			name:        "explicit chain that does not apply",
+			a:           "not base64!",
+			b:           "x",
+			mode:        "text",
+			decode:      "base64",
+			expectError: "Decode A failed",
+		},
+		{
+			name:        "auto fails rather than truncating",
+			a:           bombed,
+			b:           "x",
+			mode:        "text",
+			decode:      "auto",
+			expectError: "Decode A failed: gzip: decompressed content is larger than 16777216 bytes",
+		},
+		{
+			name:        "explicit chain fails rather than truncating",
+			a:           bombed,
+			b:           bombed,
+			mode:        "text",
+			decode:      "base64,gzip",
+			expectError: "Decode A failed: gzip: decompressed content is larger than 16777216 bytes",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			controller, r := setupTestController()
+			r.POST("/compare", controller.Compare)
+
+			w := httptest.NewRecorder()
+
+			form := url.Values{}
+			form.Add("a", tt.a)
+			form.Add("b", tt.b)
+			form.Add("mode", tt.mode)
+			form.Add("decode", tt.decode)
+
+			req := httptest.NewRequest(http.MethodPost, "/compare", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+
+			body := w.Body.String()
+			if tt.expectError != "" {
+				assert.Contains(t, body, tt.expectError)
+				return
+			}
+			for _, text := range tt.expectedText {
+				assert.Contains(t, body, text)
+			}
+			for _, text := range tt.absentText {
+				assert.NotContains(t, body, text)
+			}
+		})
+	}
+}
+
+func TestCompare_BinaryMode(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name         string
+		a            string
+		b            string
+		mode         string
+		expectedText []string
+	}{
+		{
+			name: "differing bytes highlighted with offset",
+			a:    "\x00\x01ABC",
+			b:    "\x00\x02ABCD",
+			mode: "binary",
+			expectedText: []string{
+				"Differing bytes: <strong>2</strong>",
+				"offset <code class=\"small\">0x00000001</code>",
+				"00 <mark>01 </mark>41 42 43",
+				"|.<mark>.</mark>ABC<mark>D</mark>|",
+			},
+		},
+		{
+			name:         "identical binary",
+			a:            "\x7fELF\x00",
+			b:            "\x7fELF\x00",
+			mode:         "binary",
+			expectedText: []string{"Differing bytes: <strong>0</strong>"},
+		},
+		{
+			name:         "auto mode detects binary",
+			a:            "PK\x03\x04\x00\x00",
+			b:            "PK\x03\x04\x00\x01",
+			mode:         "auto",
+			expectedText: []string{"Detected: A <strong>Binary</strong>", "Differing bytes: <strong>1</strong>"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			controller, r := setupTestController()
+			r.POST("/compare", controller.Compare)
+
+			w := httptest.NewRecorder()
+
+			form := url.Values{}
+			form.Add("a", tt.a)
+			form.Add("b", tt.b)
+			form.Add("mode", tt.mode)
+
+			req := httptest.NewRequest(http.MethodPost, "/compare", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+
+			body := w.Body.String()
+			for _, text := range tt.expectedText {
+				assert.Contains(t, body, text)
+			}
+		})
+	}
+}
+
+func TestCompare_ImageMode(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	white := func(w, h int) *image.NRGBA {
+		img := image.NewNRGBA(image.Rect(0, 0, w, h))
+		draw.Draw(img, img.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+		return img
+	}
+	encode := func(t *testing.T, img image.Image, format string) string {
+		var buf bytes.Buffer
+		if format == "gif" {
+			require.NoError(t, gif.Encode(&buf, img, nil))
+		} else {
+			require.NoError(t, png.Encode(&buf, img))
+		}
+		return buf.String()
+	}
+
+	// header is a PNG holding only its IHDR chunk, enough for DecodeConfig
+	header := func(w, h int) string {
+		ihdr := []byte("IHDR\x00\x00\x00\x00\x00\x00\x00\x00\x08\x06\x00\x00\x00")
+		binary.BigEndian.PutUint32(ihdr[4:], uint32(w))
+		binary.BigEndian.PutUint32(ihdr[8:], uint32(h))
+		chunk := binary.BigEndian.AppendUint32(nil, uint32(len(ihdr)-4))
+		chunk = append(chunk, ihdr...)
+		chunk = binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(ihdr))
+		return "\x89PNG\r\n\x1a\n" + string(chunk)
+	}
+
+	base := white(20, 20)
+	block := white(20, 20)
+	draw.Draw(block, image.Rect(5, 5, 8, 7), image.NewUniform(color.Black), image.Point{}, draw.Src)
+	noisy := white(20, 20)
+	noisy.SetNRGBA(3, 3, color.NRGBA{R: 252, G: 252, B: 252, A: 255})
+
+	tests := []struct {
+		name         string
+		a, b         string
+		mode         string
+		tolerance    string
+		expectedText []string
+	}{
+		{
+			name:         "changed region",
+			a:            encode(t, base, "png"),
+			b:            encode(t, block, "png"),
+			mode:         "image",
+			expectedText: []string{"Changed pixels: <strong>6</strong> of 400", "Regions: <strong>1</strong>", "<td>5</td>", "Difference mask", "data:image/png;base64,"},
+		},
+		{
+			name:         "within tolerance",
+			a:            encode(t, base, "png"),
+			b:            encode(t, noisy, "png"),
+			mode:         "image",
+			tolerance:    "5",
+			expectedText: []string{"Changed pixels: <strong>0</strong>", `value="5"`},
+		},
+		{
+			name:         "outside tolerance",
+			a:            encode(t, base, "png"),
+			b:            encode(t, noisy, "png"),
+			mode:         "image",
+			tolerance:    "2",
+			expectedText: []string{"Changed pixels: <strong>1</strong>"},
+		},
+		{
+			name:         "different sizes",
+			a:            encode(t, white(10, 10), "png"),
+			b:            encode(t, white(12, 10), "png"),
+			mode:         "image",
+			expectedText: []string{"A: <strong>10&times;10</strong>", "Changed pixels: <strong>20</strong> of 120"},
+		},
+		{
+			name:         "auto mode across formats",
+			a:            encode(t, block, "png"),
+			b:            encode(t, block, "gif"),
+			mode:         "auto",
+			expectedText: []string{"Detected: A <strong>Image</strong>", "Changed pixels: <strong>0</strong>", "data:image/gif;base64,"},
+		},
+		{
+			name:         "combined canvas too large",
+			a:            header(25_000_000, 1),
+			b:            header(1, 25_000_000),
+			mode:         "image",
+			expectedText: []string{"together they span 25000000x25000000, larger than 25000000 pixels"},
+		},
+		{
+			name:         "not an image",
+			a:            "hello",
+			b:            encode(t, base, "png"),
+			mode:         "image",
+			expectedText: []string{"Image parse error for A"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			controller, r := setupTestController()
+			r.POST("/compare", controller.Compare)
+
+			var buf bytes.Buffer
+			mw := multipart.NewWriter(&buf)
+			fw, _ := mw.CreateFormFile("file_a", "a")
+			fw.Write([]byte(tt.a))
+			fw, _ = mw.CreateFormFile("file_b", "b")
+			fw.Write([]byte(tt.b))
+			mw.WriteField("mode", tt.mode)
+			mw.WriteField("image_tolerance", tt.tolerance)
+			require.NoError(t, mw.Close())
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/compare", &buf)
+			req.Header.Set("Content-Type", mw.FormDataContentType())
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			body := w.Body.String()
+			for _, text := range tt.expectedText {
+				assert.Contains(t, body, text)
+			}
+		})
+	}
+}
+
+func TestCompare_WhitespaceOptions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	normalizedYes := regexp.MustCompile(`Normalized match:</strong>\s*<span class="badge bg-success">`)
+
+	tests := []struct {
+		name         string
+		a, b         string
+		options      map[string]string
+		normalized   bool
+		expectedText []string
+	}{
+		{
+			name:       "trailing whitespace",
+			a:          "foo  \nbar\t",
+			b:          "foo\nbar",
+			options:    map[string]string{"ignore_trailing_ws": "on"},
+			normalized: true,
+		},
+		{
+			name:       "trailing option keeps inner whitespace",
+			a:          "foo  bar",
+			b:          "foo bar",
+			options:    map[string]string{"ignore_trailing_ws": "on"},
+			normalized: false,
+		},
+		{
+			name:       "whitespace amount",
+			a:          "if  x {\n\treturn\t1  ",
+			b:          "if x {\n return 1",
+			options:    map[string]string{"ignore_ws_amount": "on"},
+			normalized: true,
+		},
+		{
+			name:       "amount still sees added whitespace",
+			a:          "a+b",
+			b:          "a + b",
+			options:    map[string]string{"ignore_ws_amount": "on"},
+			normalized: false,
+		},
+		{
+			name:         "all whitespace within lines",
+			a:            "a+b\nc",
+			b:            "a + b\nc",
+			options:      map[string]string{"ignore_all_ws": "on"},
+			normalized:   true,
+			expectedText: []string{`<tr class="same">`},
+		},
+		{
+			name:       "all whitespace keeps line structure",
+			a:          "a b",
+			b:          "a\nb",
+			options:    map[string]string{"ignore_all_ws": "on"},
+			normalized: false,
+		},
+		{
+			name:         "blank lines",
+			a:            "one\n\n\ntwo",
+			b:            "one\n  \ntwo",
+			options:      map[string]string{"ignore_blank_lines": "on"},
+			normalized:   true,
+			expectedText: []string{"<pre>two</pre></td>\n                        <td><pre>two</pre>"},
+		},
+		{
+			name:         "tab expansion",
+			a:            "\tx\n\ty",
+			b:            "    x\n    y",
+			options:      map[string]string{"tab_width": "4"},
+			normalized:   true,
+			expectedText: []string{"<pre>    x</pre></td>\n                        <td><pre>    x</pre>", `name="tab_width" min="0" max="16" value="4"`},
+		},
+		{
+			name:       "tab stops depend on column",
+			a:          "ab\tc",
+			b:          "ab    c",
+			options:    map[string]string{"tab_width": "4"},
+			normalized: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			controller, r := setupTestController()
+			r.POST("/compare", controller.Compare)
+
+			form := url.Values{}
+			form.Add("a", tt.a)
+			form.Add("b", tt.b)
+			form.Add("mode", "text")
+			for k, v := range tt.options {
+				form.Add(k, v)
+			}
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/compare", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			body := w.Body.String()
+			if tt.normalized {
+				assert.Regexp(t, normalizedYes, body)
+				assert.NotContains(t, body, `<tr class="changed">`)
+			} else {
+				assert.NotRegexp(t, normalizedYes, body)
+			}
+			for _, text := range tt.expectedText {
+				assert.Contains(t, body, text)
+			}
+		})
+	}
+}
+
+func TestCompare_Stats(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name         string
+		a, b         string
+		mode         string
+		expectedText []string
+	}{
+		{
+			name:         "character similarity",
+			a:            "kitten",
+			b:            "sitting",
+			mode:         "text",
+			expectedText: []string{"57.1% similar, 1 line changed", "edit distance <strong>3</strong> chars"},
+		},
+		{
+			name:         "identical",
+			a:            "same",
+			b:            "same",
+			mode:         "text",
+			expectedText: []string{"<strong>100% similar</strong>", "unchanged <strong>1</strong>"},
+		},
+		{
+			name: "line counts and edit script",
+			a:    "a\nb\nc",
+			b:    "a\nx\nc\nd",
+			mode: "text",
+			expectedText: []string{
+				"Lines added <strong>1</strong> &middot; removed <strong>0</strong>",
+				"changed <strong>1</strong> &middot; unchanged <strong>2</strong>",
+				"edit script <strong>3</strong> lines",
+			},
+		},
+		{
+			name: "json fields",
+			a:    `{"a":1,"b":2,"c":3}`,
+			b:    `{"a":1,"b":5,"d":4}`,
+			mode: "json",
+			expectedText: []string{
+				"3 fields changed",
+				"fields added <strong>1</strong> &middot; removed <strong>1</strong> &middot; changed <strong>1</strong>",
+				"<td><code>b</code></td>",
+			},
+		},
+		{
+			name:         "binary bytes",
+			a:            "\x00\x01",
+			b:            "\x00\x02",
+			mode:         "binary",
+			expectedText: []string{"50% similar", "edit distance <strong>1</strong> bytes"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			controller, r := setupTestController()
+			r.POST("/compare", controller.Compare)
+
+			form := url.Values{}
+			form.Add("a", tt.a)
+			form.Add("b", tt.b)
+			form.Add("mode", tt.mode)
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/compare", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			body := w.Body.String()
+			for _, text := range tt.expectedText {
+				assert.Contains(t, body, text)
+			}
+		})
+	}
+}
+
+func TestCompare_SetModes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name         string
+		a, b         string
+		mode         string
+		ignoreWS     bool
+		ignoreCase   bool
+		expectedText []string
+		absentText   []string
+	}{
+		{
+			name: "order ignored",
+			a:    "alpha\nbeta\ngamma",
+			b:    "gamma\nalpha\ndelta",
+			mode: "set",
+			expectedText: []string{
+				"in both: <strong>2</strong>",
+				"only in A: <strong>1</strong>",
+				"only in B: <strong>1</strong>",
+				"<td><code>beta</code></td>",
+				"<td><code>delta</code></td>",
+			},
+			absentText: []string{"<td><code>alpha</code></td>", "Side-by-side diff", "count differs"},
+		},
+		{
+			name:         "duplicates ignored in set mode",
+			a:            "a\na\nb",
+			b:            "b\na",
+			mode:         "set",
+			expectedText: []string{"only in A: <strong>0</strong>", "only in B: <strong>0</strong>"},
+			absentText:   []string{"Line differences"},
+		},
+		{
+			name: "counts compared in multiset mode",
+			a:    "a\na\nb",
+			b:    "b\na",
+			mode: "multiset",
+			expectedText: []string{
+				"count differs: <strong>1</strong>",
+				"<td><code>a</code></td>\n                        <td><pre>2</pre></td>\n                        <td><pre>1</pre></td>",
+			},
+		},
+		{
+			name:         "trim and case fold",
+			a:            "  Foo   bar\nBAZ",
+			b:            "foo bar\nbaz\n\n",
+			mode:         "set",
+			ignoreWS:     true,
+			ignoreCase:   true,
+			expectedText: []string{"in both: <strong>2</strong>", "only in B: <strong>0</strong>"},
+			absentText:   []string{"Line differences"},
+		},
+		{
+			name:         "whitespace significant by default",
+			a:            "foo ",
+			b:            "foo",
+			mode:         "set",
+			expectedText: []string{"only in A: <strong>1</strong>"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			controller, r := setupTestController()
+			r.POST("/compare", controller.Compare)
+
+			w := httptest.NewRecorder()
+
+			form := url.Values{}
+			form.Add("a", tt.a)
+			form.Add("b", tt.b)
+			form.Add("mode", tt.mode)
+			if tt.ignoreWS {
+				form.Add("ignore_ws", "on")
+			}
+			if tt.ignoreCase {
+				form.Add("ignore_case", "on")
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/compare", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+
+			body := w.Body.String()
+			for _, text := range tt.expectedText {
+				assert.Contains(t, body, text)
+			}
+			for _, text := range tt.absentText {
+				assert.NotContains(t, body, text)
+			}
+		})
+	}
+}
+
+func TestCompare_NormalizeRules(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("ProfilesFile", t.TempDir()+"/profiles.json")
+
+	exactYes := regexp.MustCompile(`Exact match:</strong>\s*<span class="badge bg-success">`)
+	normalizedYes := regexp.MustCompile(`Normalized match:</strong>\s*<span class="badge bg-success">`)
+
+	controller, r := setupTestController()
+	r.POST("/compare", controller.Compare)
+	post := func(t *testing.T, form url.Values) string {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/compare", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		return w.Body.String()
+	}
+
+	a := "deploy build-123 to web01.internal"
+	b := "deploy build-456 to web02.internal"
+	rules := "build-\\d+ => build-N\r\n# hosts\r\nweb\\d+\\.internal => HOST"
+
+	t.Run("ad-hoc rules", func(t *testing.T) {
+		body := post(t, url.Values{"a": {a}, "b": {b}, "mode": {"text"}, "rules": {rules}})
+		assert.Regexp(t, normalizedYes, body)
+		assert.NotRegexp(t, exactYes, body)
+		assert.Contains(t, body, "replaced <strong>2</strong> matches in A")
+	})
+
+	t.Run("group references", func(t *testing.T) {
+		body := post(t, url.Values{"a": {"v1.2.3"}, "b": {"v1.2.9"}, "mode": {"text"}, "rules": {`v(\d+)\.(\d+)\.\d+ => v$1.$2`}})
+		assert.Regexp(t, normalizedYes, body)
+	})
+
+	t.Run("invalid pattern", func(t *testing.T) {
+		body := post(t, url.Values{"a": {a}, "b": {b}, "mode": {"text"}, "rules": {"ok => x\n(unclosed"}})
+		assert.Contains(t, body, "Normalization rules: line 2:")
+	})
+
+	t.Run("unknown profile", func(t *testing.T) {
+		body := post(t, url.Values{"a": {a}, "b": {b}, "mode": {"text"}, "profile": {"missing"}})
+		assert.Contains(t, body, "profile &#34;missing&#34; not found")
+	})
+
+	t.Run("save, use and delete a profile", func(t *testing.T) {
+		body := post(t, url.Values{"action": {"save_profile"}, "profile_name": {"deploys"}, "rules": {rules}})
+		assert.Contains(t, body, "Saved profile &#34;deploys&#34;.")
+		assert.Contains(t, body, `<option value="deploys" title="build-\d&#43; =&gt; build-N`)
+		assert.Contains(t, body, "selected>deploys (2 rules)")
+
+		body = post(t, url.Values{"a": {a}, "b": {b}, "mode": {"text"}, "profile": {"deploys"}})
+		assert.Regexp(t, normalizedYes, body)
+
+		// Profile rules run before ad-hoc ones
+		body = post(t, url.Values{"a": {a}, "b": {b}, "mode": {"text"}, "profile": {"deploys"}, "rules": {"HOST => host"}})
+		assert.Regexp(t, normalizedYes, body)
+		assert.Contains(t, body, "replaced <strong>3</strong> matches in A")
+
+		body = post(t, url.Values{"action": {"delete_profile"}, "profile": {"deploys"}})
+		assert.Contains(t, body, "Deleted profile &#34;deploys&#34;.")
+		assert.NotContains(t, body, `<option value="deploys"`)
+	})
+
+	t.Run("save requires a name and rules", func(t *testing.T) {
+		body := post(t, url.Values{"action": {"save_profile"}, "rules": {rules}})
+		assert.Contains(t, body, "Enter a name for the profile.")
+		body = post(t, url.Values{"action": {"save_profile"}, "profile_name": {"empty"}})
+		assert.Contains(t, body, "Add at least one rule to save.")
+	})
+}
+
+func TestCompare_FileEncodings(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	utf16le := func(s string, bom bool) string {
+		var buf bytes.Buffer
+		if bom {
+			buf.WriteString("\xff\xfe")
+		}
+		for _, u := range utf16.Encode([]rune(s)) {
+			buf.WriteByte(byte(u))
+			buf.WriteByte(byte(u >> 8))
+		}
+		return buf.String()
+	}
+	utf16be := func(s string) string {
+		var buf bytes.Buffer
+		for _, u := range utf16.Encode([]rune(s)) {
+			buf.WriteByte(byte(u >> 8))
+			buf.WriteByte(byte(u))
+		}
+		return buf.String()
+	}
+
+	tests := []struct {
+		name         string
+		fileA        string
+		encodingA    string
+		fileB        string
+		textB        string
+		expectedText []string
+		absentText   []string
+		expectError  string
+	}{
+		{
+			name:         "utf-16 export with bom matches utf-8",
+			fileA:        utf16le("name,city\nJosé,Zürich", true),
+			fileB:        "name,city\nJosé,Zürich",
+			expectedText: []string{"File A encoding: <strong>UTF-16LE (BOM)</strong>", "File B encoding: <strong>UTF-8</strong>", "differ only in character encoding", "Zürich"},
+		},
+		{
+			name:         "bom-less utf-16be detected",
+			fileA:        utf16be("hello world"),
+			textB:        "hello there",
+			expectedText: []string{"File A encoding: <strong>UTF-16BE</strong>", "hello world"},
+			absentText:   []string{"differ only in character encoding"},
+		},
+		{
+			name:         "latin-1 chosen explicitly",
+			fileA:        "caf\xe9",
+			encodingA:    "latin1",
+			textB:        "café",
+			expectedText: []string{"File A encoding: <strong>Latin-1</strong>", "differ only in character encoding"},
+		},
+		{
+			name:         "shift-jis chosen explicitly",
+			fileA:        "\x93\xfa\x96\x7b",
+			encodingA:    "shift_jis",
+			textB:        "日本",
+			expectedText: []string{"File A encoding: <strong>Shift-JIS</strong>", "differ only in character encoding"},
+		},
+		{
+			name:        "unknown encoding",
+			fileA:       "abc",
+			encodingA:   "ebcdic",
+			textB:       "abc",
+			expectError: "Reading file A failed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			controller, r := setupTestController()
+			r.POST("/compare", controller.Compare)
+
+			var buf bytes.Buffer
+			mw := multipart.NewWriter(&buf)
+			fw, err := mw.CreateFormFile("file_a", "a.csv")
+			require.NoError(t, err)
+			fw.Write([]byte(tt.fileA))
+			if tt.fileB != "" {
+				fw, err = mw.CreateFormFile("file_b", "b.csv")
+				require.NoError(t, err)
+				fw.Write([]byte(tt.fileB))
+			}
+			mw.WriteField("b", tt.textB)
+			mw.WriteField("mode", "text")
+			mw.WriteField("encoding_a", tt.encodingA)
+			require.NoError(t, mw.Close())
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/compare", &buf)
+			req.Header.Set("Content-Type", mw.FormDataContentType())
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+
+			body := w.Body.String()
+			if tt.expectError != "" {
+				assert.Contains(t, body, tt.expectError)
+				return
+			}
+			for _, text := range tt.expectedText {
+				assert.Contains(t, body, text)
+			}
+			for _, text := range tt.absentText {
+				assert.NotContains(t, body, text)
+			}
+		})
+	}
+}
+
+func TestCompare_HiddenCharacters(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name         string
+		fileA        string
+		textA        string
+		textB        string
+		showWS       bool
+		expectedText []string
+		absentText   []string
+	}{
+		{
+			name:         "crlf upload vs lf text",
+			fileA:        "one\r\ntwo\r\n",
+			textB:        "one\ntwo",
+			expectedText: []string{"Hidden characters", "CRLF line endings", "Trailing newline"},
+		},
+		{
+			name:       "textarea line breaks are not reported as crlf",
+			textA:      "one\r\ntwo",
+			textB:      "one\r\ntwo",
+			absentText: []string{"Hidden characters"},
+		},
+		{
+			name:         "zero-width and non-breaking spaces",
+			textA:        "price: 10\u00a0USD",
+			textB:        "price:\u200b 10 USD",
+			expectedText: []string{"Non-breaking spaces", "line 1 col 10 (U&#43;00A0)", "Zero-width characters", "line 1 col 7 (U&#43;200B)"},
+		},
+		{
+			name:         "cyrillic look-alike in latin word",
+			textA:        "paypal",
+			textB:        "p\u0430ypal",
+			expectedText: []string{"Confusable characters", "(U&#43;0430) looks like"},
+		},
+		{
+			name:       "plain cyrillic text is not flagged",
+			textA:      "\u043f\u0440\u0438\u0432\u0435\u0442",
+			textB:      "\u043f\u0440\u0438\u0432\u0435\u0442",
+			absentText: []string{"Confusable characters"},
+		},
+		{
+			name:         "visible whitespace rendering",
+			fileA:        "a\tb\r\n",
+			textB:        "a b",
+			showWS:       true,
+			expectedText: []string{`<span class="ws">→</span>`, `<span class="ws" title="carriage return">␍</span>`, `<span class="ws">·</span>`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			controller, r := setupTestController()
+			r.POST("/compare", controller.Compare)
+
+			var buf bytes.Buffer
+			mw := multipart.NewWriter(&buf)
+			if tt.fileA != "" {
+				fw, err := mw.CreateFormFile("file_a", "a.txt")
+				require.NoError(t, err)
+				fw.Write([]byte(tt.fileA))
+			}
+			mw.WriteField("a", tt.textA)
+			mw.WriteField("b", tt.textB)
+			mw.WriteField("mode", "text")
+			if tt.showWS {
+				mw.WriteField("show_ws", "on")
+			}
+			require.NoError(t, mw.Close())
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/compare", &buf)
+			req.Header.Set("Content-Type", mw.FormDataContentType())
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+
+			body := w.Body.String()
+			for _, text := range tt.expectedText {
+				assert.Contains(t, body, text)
+			}
+			for _, text := range tt.absentText {
+				assert.NotContains(t, body, text)
+			}
+		})
+	}
+}
+
+func TestCompare_AutoMode(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name         string
+		a            string
+		b            string
+		expectedText []string
+		absentText   []string
+	}{
+		{
+			name:         "json on both sides",
+			a:            `{"a":1,"b":2}`,
+			b:            `{"b":2,"a":1}`,
+			expectedText: []string{"Detected: A <strong>JSON</strong> &middot; B <strong>JSON</strong>", `value="auto" selected`},
+			absentText:   []string{"compared as text"},
+		},
+		{
+			name:         "yaml structural changes",
+			a:            "service:\n  name: api\n  replicas: 2\n",
+			b:            "service:\n  name: api\n  replicas: 3\n  port: 8080\n",
+			expectedText: []string{"Detected: A <strong>YAML</strong>", "service.replicas", "service.port"},
+		},
+		{
+			name:         "csv rows keyed by first column",
+			a:            "id,name,price\n1,apple,2\n2,pear,3",
+			b:            "id,name,price\n2,pear,4\n1,apple,2\n3,fig,1",
+			expectedText: []string{"Detected: A <strong>CSV</strong>", "row id=2 &gt; price", "row id=3"},
+		},
+		{
+			name:         "different formats fall back to text with a warning",
+			a:            `{"name":"api"}`,
+			b:            "name: api\nport: 80",
+			expectedText: []string{"A looks like JSON but B looks like YAML, so they were compared as text."},
+		},
+		{
+			name:         "empty side uses the other side's format",
+			a:            "",
+			b:            `<root><a>1</a></root>`,
+			expectedText: []string{"Detected: A <strong>Text</strong> &middot; B <strong>XML</strong>"},
+			absentText:   []string{"compared as text"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			controller, r := setupTestController()
+			r.POST("/compare", controller.Compare)
+
+			w := httptest.NewRecorder()
+
+			form := url.Values{}
+			form.Add("a", tt.a)
+			form.Add("b", tt.b)
+			form.Add("mode", "auto")
+
+			req := httptest.NewRequest(http.MethodPost, "/compare", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+
+			body := w.Body.String()
+			for _, text := range tt.expectedText {
+				assert.Contains(t, body, text)
+			}
+			for _, text := range tt.absentText {
+				assert.NotContains(t, body, text)
+			}
+		})
+	}
+}
+
+func buildTestZip(t *testing.T, files map[string]string) string {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		require.NoError(t, err)
+		w.Write([]byte(content))
+	}
+	require.NoError(t, zw.Close())
+	return buf.String()
+}
+
+func buildTestTarGz(t *testing.T, files map[string]string) string {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}))
+		tw.Write([]byte(content))
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	return buf.String()
+}
+
+func TestCompare_Archives(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name  string
+		build func(*testing.T, map[string]string) string
+	}{
+		{name: "zip", build: buildTestZip},
+		{name: "tar.gz", build: buildTestTarGz},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			controller, r := setupTestController()
+			r.POST("/compare", controller.Compare)
+			r.GET("/compare/set", controller.CompareSet)
+			r.GET("/compare/set/entry", controller.CompareSetEntry)
+
+			// Versioned root directories are stripped so the builds line up
+			a := tt.build(t, map[string]string{
+				"app_1.0/README.md":     "hello",
+				"app_1.0/config/a.json": `{"port":80}`,
+				"app_1.0/old.txt":       "gone",
+			})
+			b := tt.build(t, map[string]string{
+				"app_1.1/README.md":     "hello",
+				"app_1.1/config/a.json": `{"port":8080}`,
+				"app_1.1/new.txt":       "fresh",
+			})
+
+			var buf bytes.Buffer
+			mw := multipart.NewWriter(&buf)
+			fw, _ := mw.CreateFormFile("file_a", "a.archive")
+			fw.Write([]byte(a))
+			fw, _ = mw.CreateFormFile("file_b", "b.archive")
+			fw.Write([]byte(b))
+			mw.WriteField("mode", "auto")
+			require.NoError(t, mw.Close())
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/compare", &buf)
+			req.Header.Set("Content-Type", mw.FormDataContentType())
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			body := w.Body.String()
+			assert.Contains(t, body, `<tr class="identical">`)
+			assert.Contains(t, body, "config/")
+			assert.Contains(t, body, "only in A")
+			assert.Contains(t, body, "only in B")
+			assert.NotContains(t, body, "Side-by-side diff")
+
+			m := regexp.MustCompile(`/compare/set/entry\?id=([0-9a-f]+)&amp;path=config%2fa.json`).FindStringSubmatch(body)
+			require.Len(t, m, 2, "entry link not found")
+
+			// Drill down into the changed file
+			w = httptest.NewRecorder()
+			req = httptest.NewRequest(http.MethodGet, "/compare/set/entry?id="+m[1]+"&path=config/a.json", nil)
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			body = w.Body.String()
+			assert.Contains(t, body, "Showing <code>config/a.json</code>")
+			assert.Contains(t, body, "Detected: A <strong>JSON</strong>")
+			assert.Contains(t, body, "8080")
+
+			// Back to the entry list
+			w = httptest.NewRecorder()
+			req = httptest.NewRequest(http.MethodGet, "/compare/set?id="+m[1], nil)
+			r.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Contains(t, w.Body.String(), "only in B")
+		})
+	}
+
+	t.Run("fails rather than truncating", func(t *testing.T) {
+		controller, r := setupTestController()
+		r.POST("/compare", controller.Compare)
+
+		big := strings.Repeat("0", 17<<20)
+		var buf bytes.Buffer
+		mw := multipart.NewWriter(&buf)
+		fw, _ := mw.CreateFormFile("file_a", "a.tar.gz")
+		fw.Write([]byte(buildTestTarGz(t, map[string]string{"big.txt": big})))
+		fw, _ = mw.CreateFormFile("file_b", "b.zip")
+		fw.Write([]byte(buildTestZip(t, map[string]string{"a.txt": "one"})))
+		require.NoError(t, mw.Close())
+
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/compare", &buf)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		r.ServeHTTP(w, req)
+		assert.Contains(t, w.Body.String(), "Reading archive A failed: big.txt: files total more than 16777216 bytes")
+	})
+
+	t.Run("sets stay out of the magic link cache", func(t *testing.T) {
+		controller, r := setupTestController()
+		r.POST("/compare", controller.Compare)
+		r.GET("/magic/peek", controller.PeekMagicKeys)
+
+		var buf bytes.Buffer
+		mw := multipart.NewWriter(&buf)
+		fw, _ := mw.CreateFormFile("file_a", "a.zip")
+		fw.Write([]byte(buildTestZip(t, map[string]string{"a.txt": "one"})))
+		fw, _ = mw.CreateFormFile("file_b", "b.zip")
+		fw.Write([]byte(buildTestZip(t, map[string]string{"a.txt": "two"})))
+		require.NoError(t, mw.Close())
+
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/compare", &buf)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		r.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "/compare/set/entry?id=")
+
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/magic/peek", nil))
+		assert.JSONEq(t, `{"keys":[]}`, w.Body.String())
+	})
+
+	t.Run("unknown set redirects", func(t *testing.T) {
+		controller, r := setupTestController()
+		r.GET("/compare/set", controller.CompareSet)
+
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/compare/set?id=missing", nil)
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusFound, w.Code)
+	})
+}
+
+func TestCompare_MultipleFiles(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name     string
+		filesA   map[string]string
+		filesB   map[string]string
+		link     string
+		contains []string
+	}{
+		{
+			name:     "folder upload strips the folder name",
+			filesA:   map[string]string{"build1/index.html": "<p>hi</p>", "build1/css/site.css": "a{}", "build1/old.js": "x"},
+			filesB:   map[string]string{"build2/index.html": "<p>hi</p>", "build2/css/site.css": "b{}", "build2/new.js": "y"},
+			link:     "css%2fsite.css",
+			contains: []string{`<tr class="identical">`, "css/", "only in A", "only in B"},
+		},
+		{
+			name:     "several files",
+			filesA:   map[string]string{"a.txt": "one", "b.txt": "two"},
+			filesB:   map[string]string{"a.txt": "one", "b.txt": "three"},
+			link:     "b.txt",
+			contains: []string{`<tr class="identical">`, "a.txt"},
+		},
+		{
+			name:     "one side only",
+			filesA:   map[string]string{"a.txt": "one"},
+			link:     "a.txt",
+			contains: []string{"only in A"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			controller, r := setupTestController()
+			r.POST("/compare", controller.Compare)
+
+			var buf bytes.Buffer
+			mw := multipart.NewWriter(&buf)
+			for field, files := range map[string]map[string]string{"files_a": tt.filesA, "files_b": tt.filesB} {
+				// An empty folder input still submits a part without a filename
+				fw, _ := mw.CreateFormFile(field, "")
+				fw.Write(nil)
+				for name, content := range files {
+					fw, _ := mw.CreateFormFile(field, name)
+					fw.Write([]byte(content))
+				}
+			}
+			require.NoError(t, mw.Close())
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/compare", &buf)
+			req.Header.Set("Content-Type", mw.FormDataContentType())
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			body := w.Body.String()
+			assert.Contains(t, body, "/compare/set/entry?id=")
+			assert.Contains(t, body, "&amp;path="+tt.link)
+			for _, c := range tt.contains {
+				assert.Contains(t, body, c)
+			}
+			assert.NotContains(t, body, "build1")
+			assert.NotContains(t, body, "Side-by-side diff")
+		})
+	}
+}
+
 func TestCompare_FormatActions(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
@@ -506,6 +2238,61 @@ func TestCompare_ModeValidation(t *testing.T) {
 			mode:         "xml",
 			expectedMode: "xml",
 		},
+		{
+			name:         "valid html mode",
+			mode:         "html",
+			expectedMode: "html",
+		},
+		{
+			name:         "valid go mode",
+			mode:         "go",
+			expectedMode: "go",
+		},
+		{
+			name:         "valid sql mode",
+			mode:         "sql",
+			expectedMode: "sql",
+		},
+		{
+			name:         "valid log mode",
+			mode:         "log",
+			expectedMode: "log",
+		},
+		{
+			name:         "valid http mode",
+			mode:         "http",
+			expectedMode: "http",
+		},
+		{
+			name:         "valid jwt mode",
+			mode:         "jwt",
+			expectedMode: "jwt",
+		},
+		{
+			name:         "valid yaml mode",
+			mode:         "yaml",
+			expectedMode: "yaml",
+		},
+		{
+			name:         "valid csv mode",
+			mode:         "csv",
+			expectedMode: "csv",
+		},
+		{
+			name:         "valid binary mode",
+			mode:         "binary",
+			expectedMode: "binary",
+		},
+		{
+			name:         "valid set mode",
+			mode:         "set",
+			expectedMode: "set",
+		},
+		{
+			name:         "valid multiset mode",
+			mode:         "multiset",
+			expectedMode: "multiset",
+		},
 		{
 			name:         "invalid mode defaults to text",
 			mode:         "invalid",
@@ -569,3 +2356,201 @@ func TestCompareUsingMagicLink_Success(t *testing.T) {
 	assert.Equal(t, http.StatusOK, w2.Code)
 	assert.Contains(t, w2.Body.String(), "<form id=\"form\" action=\"/compare\" method=\"post\">")
 }
+
+// largeTestFile returns n numbered lines, applying edit to each line number
+// first; edit returns the replacement lines.
+func largeTestFile(n int, edit func(i int, line string) []string) string {
+	var sb strings.Builder
+	for i := 1; i <= n; i++ {
+		line := fmt.Sprintf("record %06d value %d", i, i*7)
+		for _, l := range edit(i, line) {
+			sb.WriteString(l + "\n")
+		}
+	}
+	return sb.String()
+}
+
+func TestCompareStream(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	keep := func(_ int, line string) []string { return []string{line} }
+	a := largeTestFile(40000, keep)
+
+	tests := []struct {
+		name     string
+		files    map[string]string
+		error    string
+		regions  int
+		exact    bool
+		contains []string
+	}{
+		{
+			name: "edits deletions and insertions",
+			files: map[string]string{"large_a": a, "large_b": largeTestFile(40000, func(i int, line string) []string {
+				switch {
+				case i == 10000:
+					return []string{"record 010000 value CHANGED"}
+				case i >= 20000 && i < 20005:
+					return nil
+				case i == 30000:
+					return []string{line, "inserted one", "inserted two"}
+				}
+				return []string{line}
+			})},
+			regions:  3,
+			contains: []string{"3 differing regions", "CHANGED", "inserted two", "record 020004 value 140028", "page 1 of 1"},
+		},
+		{
+			name: "regions are paged",
+			files: map[string]string{"large_a": a, "large_b": largeTestFile(40000, func(i int, line string) []string {
+				if i%2000 == 0 {
+					return []string{line + " edited"}
+				}
+				return []string{line}
+			})},
+			regions:  10,
+			contains: []string{"20 differing regions", "page 1 of 2", "&amp;page=2"},
+		},
+		{
+			name:     "identical",
+			files:    map[string]string{"large_a": a, "large_b": a},
+			exact:    true,
+			contains: []string{"0 differing regions", "40000 lines"},
+		},
+		{
+			name:  "missing side",
+			files: map[string]string{"large_a": a},
+			error: "Comparing large files failed: choose a file for both A and B",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("StreamDir", t.TempDir())
+			controller, r := setupTestController()
+			r.POST("/compare/stream", controller.CompareStream)
+			r.GET("/compare/stream", controller.CompareStreamPage)
+
+			var buf bytes.Buffer
+			mw := multipart.NewWriter(&buf)
+			for _, field := range []string{"large_a", "large_b"} {
+				content, ok := tt.files[field]
+				name := field + ".log"
+				if !ok {
+					name = "" // an empty file input
+				}
+				fw, _ := mw.CreateFormFile(field, name)
+				fw.Write([]byte(content))
+			}
+			require.NoError(t, mw.Close())
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/compare/stream", &buf)
+			req.Header.Set("Content-Type", mw.FormDataContentType())
+			r.ServeHTTP(w, req)
+
+			if tt.error != "" {
+				assert.Equal(t, http.StatusOK, w.Code)
+				assert.Contains(t, w.Body.String(), tt.error)
+				return
+			}
+			require.Equal(t, http.StatusSeeOther, w.Code, w.Body.String())
+			location := w.Header().Get("Location")
+			assert.True(t, strings.HasPrefix(location, "/compare/stream?id="), location)
+
+			w = httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, location, nil))
+			assert.Equal(t, http.StatusOK, w.Code)
+			body := w.Body.String()
+			exactYes := regexp.MustCompile(`Exact match:</strong>\s*<span class="badge bg-success">`)
+			assert.Equal(t, tt.exact, exactYes.MatchString(body))
+			for _, c := range tt.contains {
+				assert.True(t, strings.Contains(body, c), "body does not contain %q", c)
+			}
+			assert.Equal(t, tt.regions, strings.Count(body, `<div class="card-header bg-white small">`))
+		})
+	}
+
+	t.Run("too many in progress", func(t *testing.T) {
+		t.Setenv("StreamDir", t.TempDir())
+		controller, r := setupTestController()
+		r.POST("/compare/stream", controller.CompareStream)
+		// Fill the store with comparisons still in progress
+		var err error
+		for i := 0; i < 100 && err == nil; i++ {
+			_, err = controller.(*baseController).streams.Create()
+		}
+		require.ErrorIs(t, err, services.ErrTooManyStreams)
+
+		var buf bytes.Buffer
+		mw := multipart.NewWriter(&buf)
+		require.NoError(t, mw.Close())
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/compare/stream", &buf)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Contains(t, w.Body.String(), "large comparisons are in progress")
+	})
+}
+
+func TestCompare_ResultCache(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	controller, r := setupTestController()
+	r.POST("/compare", controller.Compare)
+	results := controller.(*baseController).results
+
+	compare := func(form url.Values) string {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/compare", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		return w.Body.String()
+	}
+	form := url.Values{"a": {"cache one"}, "b": {"cache two"}, "mode": {"text"}}
+	first := compare(form)
+	assert.Contains(t, first, `<tr class="changed">`)
+
+	// Replace the cached result to show that the next request reuses it
+	key := resultKey(domain.PageData{Mode: "text"}, "text", "cache one", "cache two", "cache one", "cache two")
+	_, ok := results.Get(key)
+	require.True(t, ok, "result was not cached")
+	results.Add(key, domain.PageData{Mode: "text", Error: "served from cache"})
+	assert.Contains(t, compare(form), "served from cache")
+
+	// Other options are compared afresh
+	form.Set("show_ws", "on")
+	body := compare(form)
+	assert.NotContains(t, body, "served from cache")
+	assert.Contains(t, body, `<tr class="changed">`)
+}
+
+func TestResultKey(t *testing.T) {
+	base := domain.PageData{Mode: "text"}
+	key := resultKey(base, "text", "a", "b", "a", "b")
+
+	tests := []struct {
+		name         string
+		data         domain.PageData
+		mode         string
+		a, b         string
+		fullA, fullB string
+	}{
+		{"input A", base, "text", "x", "b", "x", "b"},
+		{"input B", base, "text", "a", "x", "a", "x"},
+		{"mode", base, "json", "a", "b", "a", "b"},
+		{"trailing line break", base, "text", "a", "b", "a\n", "b"},
+		{"ignore case", domain.PageData{Mode: "text", IgnoreCase: true}, "text", "a", "b", "a", "b"},
+		{"show whitespace", domain.PageData{Mode: "text", ShowWhitespace: true}, "text", "a", "b", "a", "b"},
+		{"rules", domain.PageData{Mode: "text", NormalizeRules: []domain.NormalizeRule{{Pattern: "a"}}}, "text", "a", "b", "a", "b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.NotEqual(t, key, resultKey(tt.data, tt.mode, tt.a, tt.b, tt.fullA, tt.fullB))
+		})
+	}
+
+	// Fields that do not change the result share the key
+	assert.Equal(t, key, resultKey(domain.PageData{Mode: "text", Notice: "saved", Profiles: []domain.NormalizeProfile{{Name: "p"}}}, "text", "a", "b", "a", "b"))
+}
@@ -17,6 +17,8 @@ func NewEncodeController(logger *zerolog.Logger, es *services.EncodeService) Enc
 	var newEs services.EncodeService
 	if es == nil {
 		newEs = services.NewEncodeService(logger)
+	} else {
+		newEs = *es
 	}
 
 	return &encodeController{
@@ -31,16 +33,26 @@ type EncodeController interface {
 }
 
 func (c *encodeController) EncodeSha256(ctx *gin.Context) {
-	content := ctx.GetString("content")
+	content := formValue(ctx, "content")
 	ctx.JSON(http.StatusOK, gin.H{
 		"content": c.es.EncodeSha256(content),
 	})
 }
 
 func (c *encodeController) ComputeSha256(ctx *gin.Context) {
-	content := ctx.GetString("content")
-	comparison := ctx.GetString("comparison")
+	content := formValue(ctx, "content")
+	comparison := formValue(ctx, "comparison")
 	ctx.JSON(http.StatusOK, gin.H{
 		"result": c.es.ComputeSha256(content, comparison),
 	})
 }
+
+// formValue returns the context value key when a middleware has set one,
+// otherwise the posted form field.
+func formValue(ctx *gin.Context, key string) string {
+	if v, ok := ctx.Get(key); ok {
+		s, _ := v.(string)
+		return s
+	}
+	return ctx.PostForm(key)
+}
@@ -0,0 +1,147 @@
+package public
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jroden2/holmes-go/pkg/domain"
+	"github.com/jroden2/holmes-go/pkg/services"
+	"github.com/rs/zerolog"
+)
+
+// liveHeartbeat is how often an idle event stream is written to, so proxies
+// keep it open.
+const liveHeartbeat = 30 * time.Second
+
+type liveController struct {
+	logger   *zerolog.Logger
+	profiles services.ProfileService
+	live     services.LiveService
+	results  services.ResultCacheService
+}
+
+func NewLiveController(logger *zerolog.Logger, profiles services.ProfileService, results services.ResultCacheService) LiveController {
+	c := &liveController{
+		logger:   logger,
+		profiles: profiles,
+		results:  results,
+	}
+	c.live = services.NewLiveService(logger, c.render)
+	return c
+}
+
+// LiveController compares the textareas while they are edited. Edits are
+// posted as compare requests and results arrive as server-sent events.
+type LiveController interface {
+	Open(ctx *gin.Context)
+	Edit(ctx *gin.Context)
+	Events(ctx *gin.Context)
+}
+
+// Open starts a live session and answers 201 with its ID.
+func (c *liveController) Open(ctx *gin.Context) {
+	id, err := c.live.Open()
+	if errors.Is(err, services.ErrTooManyLiveSessions) {
+		apiError(ctx, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	if err != nil {
+		c.logger.Error().Err(err).Msg("Failed to open live session")
+		apiError(ctx, http.StatusInternalServerError, err.Error())
+		return
+	}
+	ctx.JSON(http.StatusCreated, gin.H{"id": id})
+}
+
+// Edit replaces the inputs of a session and answers 202 with the edit's
+// version; the result is sent to the session's event stream.
+func (c *liveController) Edit(ctx *gin.Context) {
+	var req domain.CompareRequest
+	if !bindAPIRequest(ctx, &req) {
+		return
+	}
+	if _, _, err := prepareRequest(c.profiles, req); err != nil {
+		apiError(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	version, err := c.live.Edit(ctx.Param("id"), req)
+	if err != nil {
+		apiError(ctx, http.StatusNotFound, err.Error())
+		return
+	}
+	ctx.JSON(http.StatusAccepted, gin.H{"version": version})
+}
+
+// Events streams a "diff" event with a domain.LiveUpdate for each compared
+// edit until the client disconnects.
+func (c *liveController) Events(ctx *gin.Context) {
+	updates, stop, err := c.live.Subscribe(ctx.Param("id"))
+	if err != nil {
+		apiError(ctx, http.StatusNotFound, err.Error())
+		return
+	}
+	defer stop()
+
+	// Send the headers now, so the client sees the stream open before the
+	// first update
+	ctx.Header("Content-Type", "text/event-stream")
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Header("X-Accel-Buffering", "no")
+	ctx.Status(http.StatusOK)
+	ctx.Writer.Flush()
+
+	heartbeat := time.NewTicker(liveHeartbeat)
+	defer heartbeat.Stop()
+	ctx.Stream(func(io.Writer) bool {
+		select {
+		case update := <-updates:
+			ctx.SSEvent("diff", update)
+		case <-heartbeat.C:
+			ctx.SSEvent("ping", "")
+		case <-ctx.Request.Context().Done():
+			return false
+		}
+		return true
+	})
+}
+
+// render compares one edit and renders its side-by-side rows.
+func (c *liveController) render(req domain.CompareRequest) domain.LiveUpdate {
+	data, mode, err := prepareRequest(c.profiles, req)
+	if err != nil {
+		return domain.LiveUpdate{Error: err.Error()}
+	}
+	// A background comparison is never stopped, so there is no error
+	_ = compareCached(context.Background(), c.results, &data, mode, data.A, data.B, req.A, req.B, nil)
+
+	update := domain.LiveUpdate{
+		Mode:            data.Mode,
+		ExactMatch:      data.ExactMatch,
+		NormalizedMatch: data.NormalizedMatch,
+		Error:           data.Error,
+	}
+	if data.Stats != nil {
+		update.Summary = data.Stats.Summary()
+	}
+
+	tpl, err := loadTemplates()
+	if err != nil {
+		c.logger.Error().Err(err).Msg("Failed to load templates")
+		update.Error = "Rendering the diff failed"
+		return update
+	}
+	var rows bytes.Buffer
+	if err := tpl.ExecuteTemplate(&rows, "diff-rows", data.LineDiff); err != nil {
+		c.logger.Error().Err(err).Msg("Failed to render live diff")
+		update.Error = "Rendering the diff failed"
+		return update
+	}
+	update.Rows = rows.String()
+	return update
+}
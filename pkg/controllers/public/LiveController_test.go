@@ -0,0 +1,143 @@
+package public
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jroden2/holmes-go/pkg/domain"
+	"github.com/jroden2/holmes-go/pkg/services"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupTestLiveController(t *testing.T) *httptest.Server {
+	t.Setenv("ProfilesFile", t.TempDir()+"/profiles.json")
+	logger := zerolog.New(os.Stdout)
+	lc := NewLiveController(&logger,
+		services.NewProfileService(&logger, services.DefaultProfilesPath()),
+		services.NewResultCacheService(&logger, services.DefaultResultCacheSize()))
+
+	r := gin.New()
+	r.POST("/live", lc.Open)
+	r.POST("/live/:id", lc.Edit)
+	r.GET("/live/:id/events", lc.Events)
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// readLiveUpdate returns the next "diff" event of an event stream.
+func readLiveUpdate(t *testing.T, events *bufio.Reader) domain.LiveUpdate {
+	t.Helper()
+	var event string
+	for {
+		line, err := events.ReadString('\n')
+		require.NoError(t, err)
+		line = strings.TrimRight(line, "\n")
+		switch {
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimPrefix(line, "event:")
+		case strings.HasPrefix(line, "data:") && event == "diff":
+			var u domain.LiveUpdate
+			require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data:")), &u))
+			return u
+		}
+	}
+}
+
+func TestLive(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	srv := setupTestLiveController(t)
+
+	res, err := http.Post(srv.URL+"/live", "", nil)
+	require.NoError(t, err)
+	var session struct{ ID string }
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&session))
+	res.Body.Close()
+	require.Equal(t, http.StatusCreated, res.StatusCode)
+	require.Len(t, session.ID, 32)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/live/"+session.ID+"/events", nil)
+	stream, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer stream.Body.Close()
+	require.Equal(t, http.StatusOK, stream.StatusCode)
+	assert.Contains(t, stream.Header.Get("Content-Type"), "text/event-stream")
+	events := bufio.NewReader(stream.Body)
+
+	edit := func(body string) int {
+		t.Helper()
+		res, err := http.Post(srv.URL+"/live/"+session.ID, "application/json", strings.NewReader(body))
+		require.NoError(t, err)
+		defer res.Body.Close()
+		var out struct {
+			Version int
+			Error   string
+		}
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&out))
+		require.Equal(t, http.StatusAccepted, res.StatusCode, out.Error)
+		return out.Version
+	}
+
+	version := edit(`{"a":"one\ntwo","b":"one\nthree","mode":"text"}`)
+	u := readLiveUpdate(t, events)
+	assert.Equal(t, version, u.Version)
+	assert.Equal(t, "text", u.Mode)
+	assert.False(t, u.ExactMatch)
+	assert.NotEmpty(t, u.Summary)
+	assert.Contains(t, u.Rows, `<tr class="changed">`)
+	assert.Contains(t, u.Rows, "t<mark>hree</mark>")
+
+	version = edit(`{"a":"Same","b":"same","mode":"text","options":{"ignore_case":true}}`)
+	u = readLiveUpdate(t, events)
+	assert.Equal(t, version, u.Version)
+	assert.False(t, u.ExactMatch)
+	assert.True(t, u.NormalizedMatch)
+
+	version = edit(`{"a":"{","b":"{}","mode":"json"}`)
+	u = readLiveUpdate(t, events)
+	assert.Equal(t, version, u.Version)
+	assert.NotEmpty(t, u.Error)
+}
+
+func TestLive_Errors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	srv := setupTestLiveController(t)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{"edit unknown session", http.MethodPost, "/live/missing", `{"a":"x","b":"y"}`, http.StatusNotFound},
+		{"events of unknown session", http.MethodGet, "/live/missing/events", "", http.StatusNotFound},
+		{"malformed edit", http.MethodPost, "/live/missing", `{"a":`, http.StatusBadRequest},
+		{"unknown mode", http.MethodPost, "/live/missing", `{"a":"x","b":"y","mode":"cobol"}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(tt.method, srv.URL+tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			res, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer res.Body.Close()
+			assert.Equal(t, tt.status, res.StatusCode)
+
+			var apiErr domain.APIError
+			require.NoError(t, json.NewDecoder(res.Body).Decode(&apiErr))
+			assert.NotEmpty(t, apiErr.Error)
+		})
+	}
+}
@@ -1,24 +1,59 @@
 package public
 
 import (
+	"runtime"
+
 	"github.com/gin-gonic/gin"
+	"github.com/jroden2/holmes-go/pkg/services"
 	"github.com/rs/zerolog"
 )
 
 func Routes(route *gin.RouterGroup, logger *zerolog.Logger) {
+	// Services are created once and shared, so profiles saved in the UI
+	// apply to the API and a result cached by one controller serves the others
+	profiles := services.NewProfileService(logger, services.DefaultProfilesPath())
+	results := services.NewResultCacheService(logger, services.DefaultResultCacheSize())
+	sonic := services.NewCacheService()
+	streams := services.NewStreamService(logger, services.DefaultStreamDir())
+	sets := services.NewFileSetService(logger, services.DefaultFileSetSize())
+	jobs := services.NewJobService(logger, runtime.GOMAXPROCS(0), services.DefaultJobTimeout())
+	es := services.NewEncodeService(logger)
 
 	baseControllerGroup := route.Group("")
 	{
-		bc := NewBaseController(logger)
+		bc := NewBaseController(logger, sonic, profiles, streams, results, sets)
 		baseControllerGroup.GET("/", bc.Home)
 		baseControllerGroup.POST("/compare", bc.Compare)
+		baseControllerGroup.GET("/compare/set", bc.CompareSet)
+		baseControllerGroup.GET("/compare/set/entry", bc.CompareSetEntry)
+		baseControllerGroup.POST("/compare/stream", bc.CompareStream)
+		baseControllerGroup.GET("/compare/stream", bc.CompareStreamPage)
 		baseControllerGroup.POST("/magic/new", bc.CreateMagicKey)
 		baseControllerGroup.GET("/magic/peek", bc.PeekMagicKeys)
 		baseControllerGroup.GET("/magic", bc.CompareUsingMagicLink)
 	}
+	liveControllerGroup := route.Group("live")
+	{
+		lc := NewLiveController(logger, profiles, results)
+		liveControllerGroup.POST("", lc.Open)
+		liveControllerGroup.POST("/:id", lc.Edit)
+		liveControllerGroup.GET("/:id/events", lc.Events)
+	}
+	apiControllerGroup := route.Group("api")
+	{
+		ac := NewAPIController(logger, profiles, jobs, results)
+		apiControllerGroup.GET("/openapi.json", ac.OpenAPI)
+		apiControllerGroup.POST("/v1/compare", ac.Compare)
+		apiControllerGroup.POST("/v1/compare/batch", ac.CompareBatch)
+		apiControllerGroup.POST("/v1/jobs", ac.SubmitJob)
+		apiControllerGroup.POST("/v1/jobs/batch", ac.SubmitBatchJob)
+		apiControllerGroup.GET("/v1/jobs/:id", ac.Job)
+		apiControllerGroup.GET("/v1/jobs/:id/result", ac.JobResult)
+		apiControllerGroup.DELETE("/v1/jobs/:id", ac.CancelJob)
+	}
 	encodeControllerGroup := route.Group("sha")
 	{
-		ec := NewEncodeController(logger, nil)
+		ec := NewEncodeController(logger, &es)
 		encodeControllerGroup.POST("/encode", ec.EncodeSha256)
 		encodeControllerGroup.POST("/compute", ec.ComputeSha256)
 	}
@@ -1,13 +1,59 @@
 package domain
 
 import (
+	"fmt"
 	"html/template"
+	"math"
+	"strings"
+	"time"
 )
 
 type PageData struct {
 	A, B                 string
 	IgnoreWS, IgnoreCase bool
-	Mode                 string // "text" | "json" | "xml"
+	Mode                 string // "text" | "json" | "xml" | "html" | "go" | "sql" | "log" | "http" | "jwt" | "yaml" | "csv" | "binary" | "image" | "set" | "multiset"
+
+	// Auto is set when the mode was auto-detected. ADetected and BDetected
+	// name the format found in each input, and DetectWarning is set when they
+	// disagree.
+	Auto                 bool
+	ADetected, BDetected string
+	DetectWarning        string
+
+	// Per-line whitespace options; TabWidth 0 leaves tabs alone
+	IgnoreTrailingWS, IgnoreWSAmount, IgnoreAllWS bool
+	IgnoreBlankLines                              bool
+	TabWidth                                      int
+
+	// HTML mode options
+	IgnoreClassOrder, IgnoreScriptStyle bool
+	// Go mode options
+	IgnoreComments bool
+	// JWT mode options: HMAC secret or PEM public key used to verify signatures
+	JWTKey string
+
+	// Upload encodings chosen in the form ("auto", "utf-16le", "latin1", ...),
+	// the encodings actually used, and whether the uploads differ only in
+	// encoding
+	AEncoding, BEncoding                 string
+	ADetectedEncoding, BDetectedEncoding string
+	EncodingOnly                         bool
+
+	// Decode is "" (off), "auto" or a comma separated decoder chain such as
+	// "base64,gzip". The chains record the layers actually removed.
+	Decode                     string
+	ADecodeChain, BDecodeChain []string
+
+	// Regex normalization: the saved profile picked in the form, ad-hoc rules
+	// as typed (one per line), the combined parsed rules, and how many
+	// matches were replaced in each input. Profiles lists the saved profiles
+	// and Notice confirms a save or delete.
+	Profile                    string
+	Rules                      string
+	NormalizeRules             []NormalizeRule
+	RuleMatchesA, RuleMatchesB int
+	Profiles                   []NormalizeProfile
+	Notice                     string
 
 	ExactMatch, NormalizedMatch bool
 
@@ -15,7 +61,39 @@ type PageData struct {
 
 	AHash, BHash string
 
+	// Binary mode: number of differing bytes and offset of the first one (-1
+	// when identical)
+	DiffBytes, FirstDiffOffset int
+
+	// Image mode: per-channel tolerance (0-255) and the pixel comparison
+	ImageTolerance int
+	Image          *ImageDiff
+
+	// Set and multiset modes: how many distinct lines are shared or unique
+	SetStats *SetStats
+
+	// ShowWhitespace renders tabs, spaces, CRs and invisible characters in the
+	// line diff
+	ShowWhitespace bool
+	CharIssues     []CharIssue
+
+	// Archive and multi-file comparisons: one entry per file or directory,
+	// and the cache ID used to link to each file's diff. SetEntry is the path
+	// being shown when drilling into a set.
+	Entries  []FileEntry
+	SetID    string
+	SetEntry string
+
+	// Stream is the current page of a comparison of large files streamed to
+	// disk
+	Stream *StreamPage
+
+	// Stats summarizes the comparison; nil for set, image and file set
+	// comparisons, which report their own counts
+	Stats *DiffStats
+
 	LineDiff []LineDiffRow
+	Changes  []PathChange
 	Error    string
 }
 
@@ -26,6 +104,15 @@ type LineDiffRow struct {
 	Status       string
 }
 
+// PathChange describes a single structural difference, addressed by a
+// mode-specific path (e.g. a CSS-like selector in html mode).
+type PathChange struct {
+	Path   string `json:"path"`
+	A      string `json:"a"`
+	B      string `json:"b"`
+	Status string `json:"status"` // "added" | "removed" | "changed"
+}
+
 type DiffPayload struct {
 	ID       string `json:"id"`
 	ShortID  string `json:"short_id"`
@@ -41,3 +128,363 @@ func NewDiffPayload(original, new string) DiffPayload {
 func (dp *DiffPayload) GetID() string {
 	return dp.ShortID
 }
+
+// FileEntry is one file or directory in an archive or multi-file comparison.
+type FileEntry struct {
+	Path         string
+	Name         string // last path element, indented by Depth in the tree
+	Depth        int
+	Dir          bool
+	Status       string // "identical" | "changed" | "added" | "removed"
+	ASize, BSize int    // -1 when missing from that side
+	AHash, BHash string
+}
+
+// FileSet holds the files of both sides of an archive or multi-file
+// comparison, keyed by relative path, so single files can be diffed later.
+type FileSet struct {
+	A, B map[string][]byte
+}
+
+// StreamChunk is a run of whole lines of a streamed file, cut where the
+// content allows so that identical text yields identical chunks in both files.
+// Line is the 1-based number of its first line.
+type StreamChunk struct {
+	Offset, Size int64
+	Line, Lines  int64
+	Hash         [32]byte
+}
+
+// StreamFile describes one side of a streamed comparison.
+type StreamFile struct {
+	Name   string
+	Size   int64
+	Lines  int64
+	SHA256 string
+	Chunks int
+}
+
+// StreamRegion is a run of differing chunks, located by byte offset and line
+// in each file. A side with no bytes marks where the other side's text is
+// missing.
+type StreamRegion struct {
+	AOffset, ASize int64
+	ALine, ALines  int64
+	BOffset, BSize int64
+	BLine, BLines  int64
+	Status         string // "changed" | "added" | "removed"
+}
+
+// StreamDiff is the result of comparing two files too large to hold in memory.
+// The files stay on disk so each page of regions can be read back.
+type StreamDiff struct {
+	ID      string
+	A, B    StreamFile
+	Regions []StreamRegion
+}
+
+// StreamRegionView is a region read back from disk for display. Rows are
+// numbered from the region's first line in A.
+type StreamRegionView struct {
+	StreamRegion
+	Rows      []LineDiffRow
+	Truncated bool
+}
+
+// StreamPage is one page of the regions of a streamed comparison.
+type StreamPage struct {
+	Diff        StreamDiff
+	Regions     []StreamRegionView
+	Page, Pages int
+}
+
+// Prev and Next return the neighbouring page numbers, or 0 at either end.
+func (p StreamPage) Prev() int {
+	if p.Page > 1 {
+		return p.Page - 1
+	}
+	return 0
+}
+
+func (p StreamPage) Next() int {
+	if p.Page < p.Pages {
+		return p.Page + 1
+	}
+	return 0
+}
+
+// CharIssue counts one kind of easily missed character difference, such as
+// CRLF line endings or zero-width spaces, in each input.
+type CharIssue struct {
+	Kind string `json:"kind"`
+	A    int    `json:"a"`
+	B    int    `json:"b"`
+	AAt  string `json:"a_at,omitempty"` // first few locations, e.g. "line 3 col 7 (U+200B)"
+	BAt  string `json:"b_at,omitempty"`
+}
+
+// ImageDiff is the pixel comparison of two images. A and B are the inputs as
+// data URIs, Mask shows changed pixels in white and Overlay highlights them in
+// red over a faded copy of A, with each region outlined.
+type ImageDiff struct {
+	AWidth         int          `json:"a_width"`
+	AHeight        int          `json:"a_height"`
+	BWidth         int          `json:"b_width"`
+	BHeight        int          `json:"b_height"`
+	AFormat        string       `json:"a_format"` // "png" | "jpeg" | "gif"
+	BFormat        string       `json:"b_format"`
+	ChangedPixels  int          `json:"changed_pixels"`
+	TotalPixels    int          `json:"total_pixels"`
+	ChangedPercent float64      `json:"changed_percent"`
+	Boxes          []ImageBox   `json:"boxes"`
+	A              template.URL `json:"-"`
+	B              template.URL `json:"-"`
+	Mask           template.URL `json:"-"`
+	Overlay        template.URL `json:"-"`
+}
+
+// ImageBox is the bounding box of one region of changed pixels.
+type ImageBox struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+	W int `json:"w"`
+	H int `json:"h"`
+}
+
+// SetStats summarizes a set or multiset comparison of lines. CountChanged is
+// the number of shared lines occurring a different number of times.
+type SetStats struct {
+	ADistinct    int `json:"a_distinct"`
+	BDistinct    int `json:"b_distinct"`
+	Common       int `json:"common"`
+	OnlyA        int `json:"only_a"`
+	OnlyB        int `json:"only_b"`
+	CountChanged int `json:"count_changed"`
+}
+
+// NormalizeRule is a regex replacement applied to both inputs before they are
+// compared. Replace may reference groups as $1.
+type NormalizeRule struct {
+	Pattern string `json:"pattern"`
+	Replace string `json:"replace"`
+}
+
+// NormalizeProfile is a named, ordered list of rules saved for reuse.
+type NormalizeProfile struct {
+	Name  string          `json:"name"`
+	Rules []NormalizeRule `json:"rules"`
+}
+
+// RulesText formats the rules one per line as `pattern => replacement`.
+func (p NormalizeProfile) RulesText() string {
+	lines := make([]string, len(p.Rules))
+	for i, r := range p.Rules {
+		lines[i] = r.Pattern + " => " + r.Replace
+	}
+	return strings.Join(lines, "\n")
+}
+
+// DiffStats counts the differences found by a comparison. Line counts follow
+// the rendered side-by-side rows and path counts the structural changes.
+// Similarity (0-100) is 100 minus the edit distance as a percentage of the
+// longer input, measured in EditUnit ("chars", "lines" or "bytes").
+// EditScript is the number of line insertions and deletions turning A into B.
+// Approximate is set when inputs were too large for exact distances.
+type DiffStats struct {
+	LinesAdded     int     `json:"lines_added"`
+	LinesRemoved   int     `json:"lines_removed"`
+	LinesChanged   int     `json:"lines_changed"`
+	LinesUnchanged int     `json:"lines_unchanged"`
+	PathsAdded     int     `json:"paths_added"`
+	PathsRemoved   int     `json:"paths_removed"`
+	PathsChanged   int     `json:"paths_changed"`
+	Similarity     float64 `json:"similarity"`
+	EditDistance   int     `json:"edit_distance"`
+	EditUnit       string  `json:"edit_unit"`
+	EditScript     int     `json:"edit_script"`
+	Approximate    bool    `json:"approximate,omitempty"`
+}
+
+// Paths returns the number of structural changes.
+func (s DiffStats) Paths() int {
+	return s.PathsAdded + s.PathsRemoved + s.PathsChanged
+}
+
+// Summary reads like "94.3% similar, 3 fields changed".
+func (s DiffStats) Summary() string {
+	// Round down so nearly identical inputs never read as 100%
+	pct := math.Floor(s.Similarity*10) / 10
+	out := fmt.Sprintf("%g%% similar", pct)
+
+	lines := s.LinesAdded + s.LinesRemoved + s.LinesChanged
+	switch {
+	case s.Paths() > 0:
+		out += fmt.Sprintf(", %d %s changed", s.Paths(), plural(s.Paths(), "field"))
+	case lines > 0:
+		out += fmt.Sprintf(", %d %s changed", lines, plural(lines, "line"))
+	}
+	return out
+}
+
+func plural(n int, word string) string {
+	if n == 1 {
+		return word
+	}
+	return word + "s"
+}
+
+// CompareRequest is the body of POST /api/v1/compare. Mode defaults to
+// "auto".
+type CompareRequest struct {
+	A       string         `json:"a"`
+	B       string         `json:"b"`
+	Mode    string         `json:"mode,omitempty"`
+	Options CompareOptions `json:"options"`
+}
+
+// CompareOptions are the options of the compare form. Profile names a saved
+// normalization profile whose rules run before Rules.
+type CompareOptions struct {
+	IgnoreWS          bool            `json:"ignore_ws,omitempty"`
+	IgnoreCase        bool            `json:"ignore_case,omitempty"`
+	IgnoreTrailingWS  bool            `json:"ignore_trailing_ws,omitempty"`
+	IgnoreWSAmount    bool            `json:"ignore_ws_amount,omitempty"`
+	IgnoreAllWS       bool            `json:"ignore_all_ws,omitempty"`
+	IgnoreBlankLines  bool            `json:"ignore_blank_lines,omitempty"`
+	TabWidth          int             `json:"tab_width,omitempty"`
+	IgnoreClassOrder  bool            `json:"ignore_class_order,omitempty"`
+	IgnoreScriptStyle bool            `json:"ignore_script_style,omitempty"`
+	IgnoreComments    bool            `json:"ignore_comments,omitempty"`
+	JWTKey            string          `json:"jwt_key,omitempty"`
+	Decode            string          `json:"decode,omitempty"`
+	ImageTolerance    int             `json:"image_tolerance,omitempty"`
+	Profile           string          `json:"profile,omitempty"`
+	Rules             []NormalizeRule `json:"rules,omitempty"`
+}
+
+// CompareResult is the response of POST /api/v1/compare. Only the sections
+// that apply to the mode used are set.
+type CompareResult struct {
+	Mode            string       `json:"mode"`
+	Warning         string       `json:"warning,omitempty"`
+	ExactMatch      bool         `json:"exact_match"`
+	NormalizedMatch bool         `json:"normalized_match"`
+	A               InputSummary `json:"a"`
+	B               InputSummary `json:"b"`
+	Stats           *DiffStats   `json:"stats,omitempty"`
+	SetStats        *SetStats    `json:"set_stats,omitempty"`
+	Image           *ImageDiff   `json:"image,omitempty"`
+	Binary          *BinaryDiff  `json:"binary,omitempty"`
+	CharIssues      []CharIssue  `json:"char_issues,omitempty"`
+	Changes         []PathChange `json:"changes"`
+	Rows            []DiffRow    `json:"rows"`
+}
+
+// InputSummary describes one compared input.
+type InputSummary struct {
+	Length      int      `json:"length"`
+	SHA256      string   `json:"sha256"`
+	Detected    string   `json:"detected,omitempty"`
+	DecodeChain []string `json:"decode_chain,omitempty"`
+	RuleMatches int      `json:"rule_matches,omitempty"`
+}
+
+// BinaryDiff counts differing bytes; FirstDiffOffset is -1 when identical.
+type BinaryDiff struct {
+	DiffBytes       int `json:"diff_bytes"`
+	FirstDiffOffset int `json:"first_diff_offset"`
+}
+
+// DiffRow is a side-by-side row without the HTML highlighting.
+type DiffRow struct {
+	Line   int    `json:"line"`
+	A      string `json:"a"`
+	B      string `json:"b"`
+	Status string `json:"status"` // "same" | "changed" | "added" | "removed"
+}
+
+// BatchRequest is the body of POST /api/v1/compare/batch. Concurrency caps
+// the number of pairs compared at once; 0 means the server's limit.
+type BatchRequest struct {
+	Pairs       []BatchPair `json:"pairs"`
+	Concurrency int         `json:"concurrency,omitempty"`
+}
+
+// BatchPair is one comparison of a batch. ID is echoed in its result so
+// callers can match results to their own records.
+type BatchPair struct {
+	ID string `json:"id,omitempty"`
+	CompareRequest
+}
+
+// BatchResult holds one item per pair, in request order.
+type BatchResult struct {
+	Summary BatchSummary `json:"summary"`
+	Results []BatchItem  `json:"results"`
+}
+
+// BatchItem is the outcome of one pair: a result, or the status code and
+// error the single compare endpoint would have returned.
+type BatchItem struct {
+	Index  int            `json:"index"`
+	ID     string         `json:"id,omitempty"`
+	Status int            `json:"status"`
+	Result *CompareResult `json:"result,omitempty"`
+	Error  string         `json:"error,omitempty"`
+}
+
+// BatchSummary counts the outcomes of a batch. Matched pairs are exact or
+// normalized matches; Similarity is the mean over the compared pairs.
+type BatchSummary struct {
+	Total      int     `json:"total"`
+	Matched    int     `json:"matched"`
+	Different  int     `json:"different"`
+	Failed     int     `json:"failed"`
+	Similarity float64 `json:"similarity"`
+}
+
+// Job states. Done, failed and cancelled jobs are finished.
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobDone      = "done"
+	JobFailed    = "failed"
+	JobCancelled = "cancelled"
+)
+
+// Job is the status of an asynchronous comparison. Progress counts completed
+// steps out of Total, e.g. the pairs of a batch.
+type Job struct {
+	ID       string     `json:"id"`
+	Kind     string     `json:"kind"` // "compare" | "batch"
+	State    string     `json:"state"`
+	Progress int        `json:"progress"`
+	Total    int        `json:"total"`
+	Error    string     `json:"error,omitempty"`
+	Created  time.Time  `json:"created"`
+	Started  *time.Time `json:"started,omitempty"`
+	Finished *time.Time `json:"finished,omitempty"`
+}
+
+// IsFinished reports whether the job has stopped running.
+func (j Job) IsFinished() bool {
+	return j.State == JobDone || j.State == JobFailed || j.State == JobCancelled
+}
+
+// LiveUpdate is sent to a live session after its latest edit is compared.
+// Version is the edit it reflects; Rows is the rendered body of the
+// side-by-side table.
+type LiveUpdate struct {
+	Version         int    `json:"version"`
+	Mode            string `json:"mode"`
+	ExactMatch      bool   `json:"exact_match"`
+	NormalizedMatch bool   `json:"normalized_match"`
+	Summary         string `json:"summary,omitempty"`
+	Error           string `json:"error,omitempty"`
+	Rows            string `json:"rows"`
+}
+
+// APIError is the body of every API error response.
+type APIError struct {
+	Error string `json:"error"`
+}
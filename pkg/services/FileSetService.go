@@ -0,0 +1,106 @@
+package services
+
+import (
+	"container/list"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/jroden2/holmes-go/pkg/domain"
+	"github.com/jroden2/holmes-go/pkg/utils"
+	"github.com/rs/zerolog"
+)
+
+// maxFileSets bounds how many file sets are kept, however small.
+const maxFileSets = 32
+
+// fileOverhead approximates the memory of a stored file beyond its content.
+const fileOverhead = 128
+
+type storedFileSet struct {
+	id   string
+	set  domain.FileSet
+	size int
+}
+
+type fileSetService struct {
+	logger   *zerolog.Logger
+	maxBytes int
+	mu       sync.Mutex
+	size     int
+	order    *list.List // most recently used first
+	entries  map[string]*list.Element
+}
+
+// NewFileSetService keeps the archives and folders of recent comparisons, so
+// their files can be opened one by one, up to about maxBytes in total. The
+// least recently used are evicted first.
+func NewFileSetService(logger *zerolog.Logger, maxBytes int) FileSetService {
+	return &fileSetService{
+		logger:   logger,
+		maxBytes: maxBytes,
+		order:    list.New(),
+		entries:  map[string]*list.Element{},
+	}
+}
+
+type FileSetService interface {
+	Add(set domain.FileSet) (string, error)
+	Get(id string) (domain.FileSet, bool)
+}
+
+// DefaultFileSetSize returns $FileSetMB megabytes, or 256MB.
+func DefaultFileSetSize() int {
+	if mb, err := strconv.Atoi(os.Getenv("FileSetMB")); err == nil && mb > 0 {
+		return mb << 20
+	}
+	return 256 << 20
+}
+
+// Add stores set and returns its ID. A set larger than the whole store
+// replaces everything else, so the comparison that uploaded it still works.
+func (s *fileSetService) Add(set domain.FileSet) (string, error) {
+	id, err := utils.Generate32CharString()
+	if err != nil {
+		return "", err
+	}
+	size := fileSetSize(set)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[id] = s.order.PushFront(&storedFileSet{id: id, set: set, size: size})
+	s.size += size
+	for s.order.Len() > 1 && (s.size > s.maxBytes || s.order.Len() > maxFileSets) {
+		s.remove(s.order.Back())
+	}
+	s.logger.Debug().Str("id", id).Int("size", size).Msg("Stored file set")
+	return id, nil
+}
+
+func (s *fileSetService) Get(id string) (domain.FileSet, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	el, ok := s.entries[id]
+	if !ok {
+		return domain.FileSet{}, false
+	}
+	s.order.MoveToFront(el)
+	return el.Value.(*storedFileSet).set, true
+}
+
+// remove drops el. s.mu must be held.
+func (s *fileSetService) remove(el *list.Element) {
+	entry := s.order.Remove(el).(*storedFileSet)
+	delete(s.entries, entry.id)
+	s.size -= entry.size
+}
+
+func fileSetSize(set domain.FileSet) int {
+	n := 0
+	for _, files := range []map[string][]byte{set.A, set.B} {
+		for name, content := range files {
+			n += fileOverhead + len(name) + len(content)
+		}
+	}
+	return n
+}
@@ -0,0 +1,62 @@
+package services
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/jroden2/holmes-go/pkg/domain"
+	"github.com/rs/zerolog"
+)
+
+// fileSetOfSize returns a set whose estimated size is n bytes.
+func fileSetOfSize(n int) domain.FileSet {
+	return domain.FileSet{A: map[string][]byte{"f": []byte(strings.Repeat("x", n-fileOverhead-1))}}
+}
+
+func TestFileSetService(t *testing.T) {
+	logger := zerolog.New(io.Discard)
+
+	t.Run("evicts least recently used", func(t *testing.T) {
+		s := NewFileSetService(&logger, 3000)
+		a, _ := s.Add(fileSetOfSize(1000))
+		b, _ := s.Add(fileSetOfSize(1000))
+		c, _ := s.Add(fileSetOfSize(1000))
+		if _, ok := s.Get(a); !ok {
+			t.Fatal("Get(a) missed before the store was full")
+		}
+
+		// b is now the least recently used
+		d, _ := s.Add(fileSetOfSize(1000))
+		for id, want := range map[string]bool{a: true, b: false, c: true, d: true} {
+			if _, ok := s.Get(id); ok != want {
+				t.Errorf("Get(%s) found = %v, want %v", id, ok, want)
+			}
+		}
+	})
+
+	t.Run("bounds the number of sets", func(t *testing.T) {
+		s := NewFileSetService(&logger, 1<<20)
+		first, _ := s.Add(domain.FileSet{})
+		for i := 0; i < maxFileSets; i++ {
+			if _, err := s.Add(domain.FileSet{}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, ok := s.Get(first); ok {
+			t.Errorf("more than %d sets were kept", maxFileSets)
+		}
+	})
+
+	t.Run("keeps an oversized set alone", func(t *testing.T) {
+		s := NewFileSetService(&logger, 3000)
+		a, _ := s.Add(fileSetOfSize(1000))
+		big, _ := s.Add(fileSetOfSize(5000))
+		if _, ok := s.Get(big); !ok {
+			t.Error("the newest set was evicted")
+		}
+		if _, ok := s.Get(a); ok {
+			t.Error("an older set was kept beside an oversized one")
+		}
+	})
+}
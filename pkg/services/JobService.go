@@ -0,0 +1,286 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/jroden2/holmes-go/pkg/domain"
+	"github.com/jroden2/holmes-go/pkg/utils"
+	"github.com/rs/zerolog"
+)
+
+const (
+	// maxPendingJobs bounds the jobs queued or running at once.
+	maxPendingJobs = 100
+	// jobRetention is how long finished jobs and their results are kept.
+	jobRetention = time.Hour
+	// maxResultBytes bounds the results of finished jobs kept at once, as
+	// JSON.
+	maxResultBytes = 256 << 20
+)
+
+var (
+	ErrJobNotFound    = errors.New("job not found")
+	ErrJobNotFinished = errors.New("job has not finished")
+	ErrJobFinished    = errors.New("job has already finished")
+	ErrJobCancelled   = errors.New("job was cancelled")
+	ErrTooManyJobs    = fmt.Errorf("more than %d jobs are pending", maxPendingJobs)
+)
+
+// JobFunc runs a job, reporting progress as steps done out of total. It should
+// return promptly once ctx is done.
+type JobFunc func(ctx context.Context, progress func(done, total int)) (any, error)
+
+type job struct {
+	status domain.Job
+	result any
+	size   int
+	err    error
+	cancel context.CancelFunc
+}
+
+type jobService struct {
+	logger   *zerolog.Logger
+	timeout  time.Duration
+	slots    chan struct{}
+	maxBytes int
+	mu       sync.Mutex
+	jobs     map[string]*job
+	size     int
+}
+
+// NewJobService runs at most workers jobs at once, each limited to timeout.
+func NewJobService(logger *zerolog.Logger, workers int, timeout time.Duration) JobService {
+	return &jobService{
+		logger:   logger,
+		timeout:  timeout,
+		slots:    make(chan struct{}, max(workers, 1)),
+		maxBytes: maxResultBytes,
+		jobs:     map[string]*job{},
+	}
+}
+
+type JobService interface {
+	Submit(kind string, fn JobFunc) (domain.Job, error)
+	Get(id string) (domain.Job, bool)
+	Result(id string) (any, error)
+	Cancel(id string) (domain.Job, error)
+}
+
+// DefaultJobTimeout returns $JobTimeout, e.g. "30m", or 10 minutes.
+func DefaultJobTimeout() time.Duration {
+	if d, err := time.ParseDuration(os.Getenv("JobTimeout")); err == nil && d > 0 {
+		return d
+	}
+	return 10 * time.Minute
+}
+
+// Submit queues fn and returns the new job.
+func (s *jobService) Submit(kind string, fn JobFunc) (domain.Job, error) {
+	id, err := utils.Generate32CharString()
+	if err != nil {
+		return domain.Job{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prune()
+	pending := 0
+	for _, j := range s.jobs {
+		if !j.status.IsFinished() {
+			pending++
+		}
+	}
+	if pending >= maxPendingJobs {
+		return domain.Job{}, ErrTooManyJobs
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	j := &job{
+		status: domain.Job{ID: id, Kind: kind, State: domain.JobQueued, Created: time.Now()},
+		cancel: cancel,
+	}
+	s.jobs[id] = j
+	go s.run(ctx, j, fn)
+	return j.status, nil
+}
+
+func (s *jobService) Get(id string) (domain.Job, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prune()
+	j, ok := s.jobs[id]
+	if !ok {
+		return domain.Job{}, false
+	}
+	return j.status, true
+}
+
+// Result returns the result of a done job, or the error it failed with.
+func (s *jobService) Result(id string) (any, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prune()
+	j, ok := s.jobs[id]
+	switch {
+	case !ok:
+		return nil, ErrJobNotFound
+	case j.status.State == domain.JobCancelled:
+		return nil, ErrJobCancelled
+	case !j.status.IsFinished():
+		return nil, ErrJobNotFinished
+	}
+	return j.result, j.err
+}
+
+// Cancel stops a queued or running job. Its result, if it still completes,
+// is discarded.
+func (s *jobService) Cancel(id string) (domain.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[id]
+	if !ok {
+		return domain.Job{}, ErrJobNotFound
+	}
+	if j.status.IsFinished() {
+		return j.status, ErrJobFinished
+	}
+	s.finish(j, domain.JobCancelled, nil, 0, nil)
+	return j.status, nil
+}
+
+// run waits for a worker slot, then runs fn until it returns, the job is
+// cancelled or it times out. fn keeps its slot until it returns, so a job
+// that ignores ctx still counts against the limit.
+func (s *jobService) run(ctx context.Context, j *job, fn JobFunc) {
+	select {
+	case s.slots <- struct{}{}:
+	case <-ctx.Done():
+		return
+	}
+
+	s.mu.Lock()
+	if j.status.IsFinished() {
+		s.mu.Unlock()
+		<-s.slots
+		return
+	}
+	now := time.Now()
+	j.status.State, j.status.Started = domain.JobRunning, &now
+	s.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	type outcome struct {
+		result any
+		size   int
+		err    error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		defer func() { <-s.slots }()
+		defer func() {
+			if r := recover(); r != nil {
+				s.logger.Error().Str("job", j.status.ID).Interface("panic", r).Bytes("stack", debug.Stack()).Msg("Job panicked")
+				done <- outcome{err: fmt.Errorf("job panicked: %v", r)}
+			}
+		}()
+		result, err := fn(ctx, func(progress, total int) {
+			s.mu.Lock()
+			j.status.Progress, j.status.Total = progress, total
+			s.mu.Unlock()
+		})
+		out := outcome{result: result, err: err}
+		if err == nil && result != nil {
+			// Measured here, so a large result does not hold s.mu
+			out.size, out.err = s.measure(result)
+		}
+		if out.err != nil {
+			out.result = nil
+		}
+		done <- out
+	}()
+
+	var out outcome
+	select {
+	case out = <-done:
+	case <-ctx.Done():
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		s.logger.Warn().Str("job", j.status.ID).Msg("Job timed out")
+		s.finish(j, domain.JobFailed, nil, 0, fmt.Errorf("job timed out after %s", s.timeout))
+	case ctx.Err() != nil:
+		// Cancelled; Cancel has already finished the job
+	case out.err != nil:
+		s.finish(j, domain.JobFailed, nil, 0, out.err)
+	default:
+		s.finish(j, domain.JobDone, out.result, out.size, nil)
+	}
+}
+
+// measure returns the size of result as JSON. A result larger than all the
+// results kept is an error.
+func (s *jobService) measure(result any) (int, error) {
+	blob, err := json.Marshal(result)
+	if err != nil {
+		return 0, err
+	}
+	if len(blob) > s.maxBytes {
+		return 0, fmt.Errorf("result of %d bytes is larger than the %d bytes kept", len(blob), s.maxBytes)
+	}
+	return len(blob), nil
+}
+
+// finish records the outcome of j, whose result measures size bytes, unless
+// it already finished. s.mu must be held.
+func (s *jobService) finish(j *job, state string, result any, size int, err error) {
+	if j.status.IsFinished() {
+		return
+	}
+	j.cancel()
+	now := time.Now()
+	j.status.State, j.status.Finished = state, &now
+	j.result, j.size, j.err = result, size, err
+	if err != nil {
+		j.status.Error = err.Error()
+	}
+	s.size += j.size
+	s.prune()
+}
+
+// prune drops jobs that finished more than jobRetention ago, then the oldest
+// finished jobs until their results fit in maxBytes. s.mu must be held.
+func (s *jobService) prune() {
+	for id, j := range s.jobs {
+		if j.status.Finished != nil && time.Since(*j.status.Finished) > jobRetention {
+			s.drop(id)
+		}
+	}
+	for s.size > s.maxBytes {
+		var oldest string
+		for id, j := range s.jobs {
+			if j.size > 0 && (oldest == "" || j.status.Finished.Before(*s.jobs[oldest].status.Finished)) {
+				oldest = id
+			}
+		}
+		s.logger.Debug().Str("job", oldest).Msg("Dropped job to bound retained results")
+		s.drop(oldest)
+	}
+}
+
+// drop forgets the job id. s.mu must be held.
+func (s *jobService) drop(id string) {
+	s.size -= s.jobs[id].size
+	delete(s.jobs, id)
+}
@@ -0,0 +1,188 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jroden2/holmes-go/pkg/domain"
+	"github.com/rs/zerolog"
+)
+
+// waitJob polls until the job reaches state or the test times out.
+func waitJob(t *testing.T, s JobService, id, state string) domain.Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if job, _ := s.Get(id); job.State == state {
+			return job
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	job, _ := s.Get(id)
+	t.Fatalf("job %s is %s, want %s", id, job.State, state)
+	return job
+}
+
+func TestJobService(t *testing.T) {
+	logger := zerolog.New(io.Discard)
+
+	t.Run("done", func(t *testing.T) {
+		s := NewJobService(&logger, 1, time.Minute)
+		job, err := s.Submit("compare", func(_ context.Context, progress func(done, total int)) (any, error) {
+			progress(3, 3)
+			return "result", nil
+		})
+		if err != nil {
+			t.Fatalf("Submit() error = %v", err)
+		}
+		if job.State != domain.JobQueued || job.Kind != "compare" || len(job.ID) != 32 {
+			t.Errorf("Submit() = %+v, want a queued compare job", job)
+		}
+
+		job = waitJob(t, s, job.ID, domain.JobDone)
+		if job.Progress != 3 || job.Total != 3 || job.Started == nil || job.Finished == nil {
+			t.Errorf("done job = %+v, want progress 3/3 with start and finish times", job)
+		}
+		if res, err := s.Result(job.ID); err != nil || res != "result" {
+			t.Errorf("Result() = %v, %v, want result", res, err)
+		}
+		if _, err := s.Cancel(job.ID); !errors.Is(err, ErrJobFinished) {
+			t.Errorf("Cancel() of a done job error = %v, want ErrJobFinished", err)
+		}
+	})
+
+	t.Run("failed", func(t *testing.T) {
+		s := NewJobService(&logger, 1, time.Minute)
+		boom := errors.New("boom")
+		job, _ := s.Submit("compare", func(context.Context, func(int, int)) (any, error) {
+			return nil, boom
+		})
+
+		job = waitJob(t, s, job.ID, domain.JobFailed)
+		if job.Error != "boom" {
+			t.Errorf("Error = %q, want boom", job.Error)
+		}
+		if _, err := s.Result(job.ID); !errors.Is(err, boom) {
+			t.Errorf("Result() error = %v, want boom", err)
+		}
+	})
+
+	t.Run("panic", func(t *testing.T) {
+		s := NewJobService(&logger, 1, time.Minute)
+		job, _ := s.Submit("compare", func(context.Context, func(int, int)) (any, error) {
+			panic("boom")
+		})
+
+		job = waitJob(t, s, job.ID, domain.JobFailed)
+		if job.Error != "job panicked: boom" {
+			t.Errorf("Error = %q, want the panic", job.Error)
+		}
+
+		// The worker slot was released
+		next, _ := s.Submit("compare", func(context.Context, func(int, int)) (any, error) {
+			return "result", nil
+		})
+		waitJob(t, s, next.ID, domain.JobDone)
+	})
+
+	t.Run("cancel running and queued", func(t *testing.T) {
+		s := NewJobService(&logger, 1, time.Minute)
+		block := func(ctx context.Context, _ func(int, int)) (any, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		running, _ := s.Submit("batch", block)
+		waitJob(t, s, running.ID, domain.JobRunning)
+		queued, _ := s.Submit("batch", block)
+
+		if _, err := s.Result(queued.ID); !errors.Is(err, ErrJobNotFinished) {
+			t.Errorf("Result() of a queued job error = %v, want ErrJobNotFinished", err)
+		}
+		for _, id := range []string{queued.ID, running.ID} {
+			job, err := s.Cancel(id)
+			if err != nil || job.State != domain.JobCancelled {
+				t.Errorf("Cancel(%s) = %s, %v, want cancelled", id, job.State, err)
+			}
+			if _, err := s.Result(id); !errors.Is(err, ErrJobCancelled) {
+				t.Errorf("Result() of a cancelled job error = %v, want ErrJobCancelled", err)
+			}
+		}
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		s := NewJobService(&logger, 1, 20*time.Millisecond)
+		job, _ := s.Submit("compare", func(ctx context.Context, _ func(int, int)) (any, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		})
+
+		job = waitJob(t, s, job.ID, domain.JobFailed)
+		if job.Error != "job timed out after 20ms" {
+			t.Errorf("Error = %q, want a timeout", job.Error)
+		}
+	})
+
+	t.Run("expired jobs are pruned", func(t *testing.T) {
+		s := NewJobService(&logger, 1, time.Minute)
+		job, _ := s.Submit("compare", func(context.Context, func(int, int)) (any, error) {
+			return "result", nil
+		})
+		waitJob(t, s, job.ID, domain.JobDone)
+
+		js := s.(*jobService)
+		js.mu.Lock()
+		finished := time.Now().Add(-2 * jobRetention)
+		js.jobs[job.ID].status.Finished = &finished
+		js.mu.Unlock()
+		if _, ok := s.Get(job.ID); ok {
+			t.Error("Get() found a job finished longer ago than the retention")
+		}
+	})
+
+	t.Run("results are bounded in bytes", func(t *testing.T) {
+		s := NewJobService(&logger, 1, time.Minute)
+		s.(*jobService).maxBytes = 30
+		result := func(v string) JobFunc {
+			return func(context.Context, func(int, int)) (any, error) { return v, nil }
+		}
+
+		// Each result is 12 bytes as JSON, so only two fit
+		var ids []string
+		for _, v := range []string{"aaaaaaaaaa", "bbbbbbbbbb", "cccccccccc"} {
+			job, _ := s.Submit("compare", result(v))
+			waitJob(t, s, job.ID, domain.JobDone)
+			ids = append(ids, job.ID)
+		}
+		if _, err := s.Result(ids[0]); !errors.Is(err, ErrJobNotFound) {
+			t.Errorf("Result() of the oldest job error = %v, want ErrJobNotFound", err)
+		}
+		for _, id := range ids[1:] {
+			if _, err := s.Result(id); err != nil {
+				t.Errorf("Result(%s) error = %v", id, err)
+			}
+		}
+
+		big, _ := s.Submit("compare", result(strings.Repeat("x", 40)))
+		job := waitJob(t, s, big.ID, domain.JobFailed)
+		if !strings.Contains(job.Error, "larger than the 30 bytes kept") {
+			t.Errorf("Error = %q, want the result to be too large", job.Error)
+		}
+	})
+
+	t.Run("unknown job", func(t *testing.T) {
+		s := NewJobService(&logger, 1, time.Minute)
+		if _, ok := s.Get("missing"); ok {
+			t.Error("Get() found a missing job")
+		}
+		if _, err := s.Result("missing"); !errors.Is(err, ErrJobNotFound) {
+			t.Errorf("Result() error = %v, want ErrJobNotFound", err)
+		}
+		if _, err := s.Cancel("missing"); !errors.Is(err, ErrJobNotFound) {
+			t.Errorf("Cancel() error = %v, want ErrJobNotFound", err)
+		}
+	})
+}
@@ -0,0 +1,172 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/jroden2/holmes-go/pkg/domain"
+	"github.com/jroden2/holmes-go/pkg/utils"
+	"github.com/rs/zerolog"
+)
+
+const (
+	// maxLiveSessions bounds the live sessions kept at once.
+	maxLiveSessions = 100
+	// liveIdle is how long a live session without listeners is kept after
+	// its last edit.
+	liveIdle = time.Hour
+)
+
+var (
+	ErrLiveNotFound        = errors.New("live session not found")
+	ErrTooManyLiveSessions = fmt.Errorf("more than %d live sessions are open", maxLiveSessions)
+)
+
+// LiveFunc compares one edit of a live session.
+type LiveFunc func(req domain.CompareRequest) domain.LiveUpdate
+
+type liveSession struct {
+	pending *domain.CompareRequest
+	version int
+	running bool
+	last    *domain.LiveUpdate
+	subs    map[chan domain.LiveUpdate]struct{}
+	seen    time.Time
+}
+
+type liveService struct {
+	logger   *zerolog.Logger
+	compare  LiveFunc
+	mu       sync.Mutex
+	sessions map[string]*liveSession
+}
+
+// NewLiveService compares the edits of live sessions with compare and sends
+// the results to their listeners.
+func NewLiveService(logger *zerolog.Logger, compare LiveFunc) LiveService {
+	return &liveService{
+		logger:   logger,
+		compare:  compare,
+		sessions: map[string]*liveSession{},
+	}
+}
+
+type LiveService interface {
+	Open() (string, error)
+	Edit(id string, req domain.CompareRequest) (int, error)
+	Subscribe(id string) (<-chan domain.LiveUpdate, func(), error)
+}
+
+// Open starts a live session and returns its ID.
+func (s *liveService) Open() (string, error) {
+	id, err := utils.Generate32CharString()
+	if err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prune()
+	if len(s.sessions) >= maxLiveSessions {
+		return "", ErrTooManyLiveSessions
+	}
+	s.sessions[id] = &liveSession{subs: map[chan domain.LiveUpdate]struct{}{}, seen: time.Now()}
+	return id, nil
+}
+
+// Edit replaces the inputs of session id and returns the edit's version.
+// Edits are compared one at a time; an edit replaced before its turn is
+// skipped, so a burst of keystrokes costs one comparison.
+func (s *liveService) Edit(id string, req domain.CompareRequest) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.sessions[id]
+	if !ok {
+		return 0, ErrLiveNotFound
+	}
+	sess.version++
+	sess.pending, sess.seen = &req, time.Now()
+	if !sess.running {
+		sess.running = true
+		go s.work(sess)
+	}
+	return sess.version, nil
+}
+
+// Subscribe returns a channel receiving the updates of session id, starting
+// with the latest if there is one, and a function to stop listening. A slow
+// listener only misses updates that have been superseded.
+func (s *liveService) Subscribe(id string) (<-chan domain.LiveUpdate, func(), error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.sessions[id]
+	if !ok {
+		return nil, nil, ErrLiveNotFound
+	}
+	ch := make(chan domain.LiveUpdate, 1)
+	if sess.last != nil {
+		ch <- *sess.last
+	}
+	sess.subs[ch] = struct{}{}
+	return ch, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(sess.subs, ch)
+		sess.seen = time.Now()
+	}, nil
+}
+
+// work compares the pending edit of sess until none is left.
+func (s *liveService) work(sess *liveSession) {
+	for {
+		s.mu.Lock()
+		if sess.pending == nil {
+			sess.running = false
+			s.mu.Unlock()
+			return
+		}
+		req, version := *sess.pending, sess.version
+		sess.pending = nil
+		s.mu.Unlock()
+
+		update := s.run(req)
+		update.Version = version
+
+		s.mu.Lock()
+		sess.last = &update
+		for ch := range sess.subs {
+			// Replace an update the listener has not taken yet
+			select {
+			case <-ch:
+			default:
+			}
+			ch <- update
+		}
+		s.mu.Unlock()
+	}
+}
+
+// run compares req. A panic is sent to the listeners as an error, and the
+// session keeps taking edits.
+func (s *liveService) run(req domain.CompareRequest) (update domain.LiveUpdate) {
+	defer func() {
+		if r := recover(); r != nil {
+			s.logger.Error().Interface("panic", r).Bytes("stack", debug.Stack()).Msg("Live comparison panicked")
+			update = domain.LiveUpdate{Mode: req.Mode, Error: fmt.Sprintf("comparison panicked: %v", r)}
+		}
+	}()
+	return s.compare(req)
+}
+
+// prune drops sessions without listeners that have been idle for liveIdle.
+// s.mu must be held.
+func (s *liveService) prune() {
+	for id, sess := range s.sessions {
+		if len(sess.subs) == 0 && !sess.running && time.Since(sess.seen) > liveIdle {
+			delete(s.sessions, id)
+		}
+	}
+}
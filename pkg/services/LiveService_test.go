@@ -0,0 +1,113 @@
+package services
+
+import (
+	"errors"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jroden2/holmes-go/pkg/domain"
+	"github.com/rs/zerolog"
+)
+
+// nextUpdate waits for an update on ch or fails the test.
+func nextUpdate(t *testing.T, ch <-chan domain.LiveUpdate) domain.LiveUpdate {
+	t.Helper()
+	select {
+	case u := <-ch:
+		return u
+	case <-time.After(5 * time.Second):
+		t.Fatal("no live update received")
+		return domain.LiveUpdate{}
+	}
+}
+
+func TestLiveService(t *testing.T) {
+	logger := zerolog.New(io.Discard)
+
+	t.Run("coalesces edits", func(t *testing.T) {
+		started, release := make(chan struct{}), make(chan struct{})
+		var calls atomic.Int32
+		s := NewLiveService(&logger, func(req domain.CompareRequest) domain.LiveUpdate {
+			if calls.Add(1) == 1 {
+				close(started)
+				<-release
+			}
+			return domain.LiveUpdate{Summary: req.A}
+		})
+		id, err := s.Open()
+		if err != nil {
+			t.Fatalf("Open() error = %v", err)
+		}
+		updates, stop, err := s.Subscribe(id)
+		if err != nil {
+			t.Fatalf("Subscribe() error = %v", err)
+		}
+		defer stop()
+
+		for i, a := range []string{"a", "ab", "abc", "abcd"} {
+			version, err := s.Edit(id, domain.CompareRequest{A: a})
+			if err != nil || version != i+1 {
+				t.Fatalf("Edit(%q) = %d, %v, want version %d", a, version, err, i+1)
+			}
+			if i == 0 {
+				<-started
+			}
+		}
+		close(release)
+
+		// The edits queued behind the first are compared once, as the last.
+		// The listener may miss the first update once it is superseded.
+		u := nextUpdate(t, updates)
+		if u.Version == 1 {
+			u = nextUpdate(t, updates)
+		}
+		if u.Version != 4 || u.Summary != "abcd" {
+			t.Errorf("last update = %+v, want version 4 of abcd", u)
+		}
+		if n := calls.Load(); n != 2 {
+			t.Errorf("compared %d times, want 2", n)
+		}
+
+		// A new listener starts with the latest update
+		late, stopLate, _ := s.Subscribe(id)
+		defer stopLate()
+		if u := nextUpdate(t, late); u.Version != 4 {
+			t.Errorf("late listener got version %d, want 4", u.Version)
+		}
+	})
+
+	t.Run("panic", func(t *testing.T) {
+		s := NewLiveService(&logger, func(req domain.CompareRequest) domain.LiveUpdate {
+			if req.A == "boom" {
+				panic("boom")
+			}
+			return domain.LiveUpdate{Summary: req.A}
+		})
+		id, _ := s.Open()
+		updates, stop, _ := s.Subscribe(id)
+		defer stop()
+
+		s.Edit(id, domain.CompareRequest{A: "boom"})
+		if u := nextUpdate(t, updates); u.Version != 1 || u.Error != "comparison panicked: boom" {
+			t.Errorf("update = %+v, want the panic as an error", u)
+		}
+
+		// The session still compares later edits
+		s.Edit(id, domain.CompareRequest{A: "ok"})
+		if u := nextUpdate(t, updates); u.Version != 2 || u.Summary != "ok" {
+			t.Errorf("update after the panic = %+v, want version 2 of ok", u)
+		}
+	})
+
+	t.Run("unknown session", func(t *testing.T) {
+		s := NewLiveService(&logger, func(domain.CompareRequest) domain.LiveUpdate { return domain.LiveUpdate{} })
+		if _, err := s.Edit("missing", domain.CompareRequest{}); !errors.Is(err, ErrLiveNotFound) {
+			t.Errorf("Edit() error = %v, want ErrLiveNotFound", err)
+		}
+		if _, _, err := s.Subscribe("missing"); !errors.Is(err, ErrLiveNotFound) {
+			t.Errorf("Subscribe() error = %v, want ErrLiveNotFound", err)
+		}
+	})
+}
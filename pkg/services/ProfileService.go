@@ -0,0 +1,150 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/jroden2/holmes-go/pkg/domain"
+	"github.com/rs/zerolog"
+)
+
+type profileService struct {
+	logger *zerolog.Logger
+	path   string
+	mu     sync.Mutex
+}
+
+// NewProfileService stores normalization profiles as JSON in the file at
+// path, which is created on the first save.
+func NewProfileService(logger *zerolog.Logger, path string) ProfileService {
+	return &profileService{
+		logger: logger,
+		path:   path,
+	}
+}
+
+type ProfileService interface {
+	List() []domain.NormalizeProfile
+	Get(name string) (domain.NormalizeProfile, bool)
+	Save(profile domain.NormalizeProfile) error
+	Delete(name string) error
+}
+
+// DefaultProfilesPath returns $ProfilesFile, or profiles.json in the user's
+// config directory.
+func DefaultProfilesPath() string {
+	if path := os.Getenv("ProfilesFile"); path != "" {
+		return path
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "profiles.json"
+	}
+	return filepath.Join(dir, "holmes", "profiles.json")
+}
+
+func (s *profileService) List() []domain.NormalizeProfile {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	profiles, err := s.load()
+	if err != nil {
+		s.logger.Error().Err(err).Msg("Failed to load profiles")
+	}
+	return profiles
+}
+
+func (s *profileService) Get(name string) (domain.NormalizeProfile, bool) {
+	for _, p := range s.List() {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return domain.NormalizeProfile{}, false
+}
+
+// Save adds profile or replaces the one with the same name.
+func (s *profileService) Save(profile domain.NormalizeProfile) error {
+	if profile.Name == "" {
+		return errors.New("profile name is required")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	profiles, err := s.load()
+	if err != nil {
+		return err
+	}
+	replaced := false
+	for i := range profiles {
+		if profiles[i].Name == profile.Name {
+			profiles[i] = profile
+			replaced = true
+		}
+	}
+	if !replaced {
+		profiles = append(profiles, profile)
+	}
+	return s.store(profiles)
+}
+
+func (s *profileService) Delete(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	profiles, err := s.load()
+	if err != nil {
+		return err
+	}
+	kept := profiles[:0]
+	for _, p := range profiles {
+		if p.Name != name {
+			kept = append(kept, p)
+		}
+	}
+	if len(kept) == len(profiles) {
+		return fmt.Errorf("profile %q not found", name)
+	}
+	return s.store(kept)
+}
+
+// load reads the profiles sorted by name; a missing file means none.
+func (s *profileService) load() ([]domain.NormalizeProfile, error) {
+	blob, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var profiles []domain.NormalizeProfile
+	if err := json.Unmarshal(blob, &profiles); err != nil {
+		return nil, fmt.Errorf("%s: %w", s.path, err)
+	}
+	sort.Slice(profiles, func(i, j int) bool { return profiles[i].Name < profiles[j].Name })
+	return profiles, nil
+}
+
+// store writes the profiles through a temporary file so a failed write never
+// leaves a truncated file behind.
+func (s *profileService) store(profiles []domain.NormalizeProfile) error {
+	blob, err := json.MarshalIndent(profiles, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, blob, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
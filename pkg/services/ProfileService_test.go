@@ -0,0 +1,56 @@
+package services
+
+import (
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/jroden2/holmes-go/pkg/domain"
+	"github.com/rs/zerolog"
+)
+
+func TestProfileService(t *testing.T) {
+	logger := zerolog.New(io.Discard)
+	path := filepath.Join(t.TempDir(), "nested", "profiles.json")
+	service := NewProfileService(&logger, path)
+
+	if got := service.List(); len(got) != 0 {
+		t.Fatalf("List() on a missing file = %v, want none", got)
+	}
+
+	build := domain.NormalizeProfile{Name: "build", Rules: []domain.NormalizeRule{{Pattern: `build-\d+`, Replace: "build-N"}}}
+	hosts := domain.NormalizeProfile{Name: "hosts", Rules: []domain.NormalizeRule{{Pattern: `web\d+\.internal`}}}
+	for _, p := range []domain.NormalizeProfile{hosts, build} {
+		if err := service.Save(p); err != nil {
+			t.Fatalf("Save(%s) error = %v", p.Name, err)
+		}
+	}
+
+	// Profiles persist across instances and are sorted by name
+	reopened := NewProfileService(&logger, path)
+	got := reopened.List()
+	if len(got) != 2 || got[0].Name != "build" || got[1].Name != "hosts" {
+		t.Fatalf("List() = %v, want build, hosts", got)
+	}
+
+	build.Rules[0].Replace = "build-X"
+	if err := reopened.Save(build); err != nil {
+		t.Fatalf("Save() replacing error = %v", err)
+	}
+	if p, ok := reopened.Get("build"); !ok || p.Rules[0].Replace != "build-X" {
+		t.Errorf("Get(build) = %v, %v, want replaced rule", p, ok)
+	}
+
+	if err := reopened.Delete("hosts"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, ok := reopened.Get("hosts"); ok {
+		t.Error("Get(hosts) found a deleted profile")
+	}
+	if err := reopened.Delete("hosts"); err == nil {
+		t.Error("Delete() of a missing profile succeeded")
+	}
+	if err := reopened.Save(domain.NormalizeProfile{}); err == nil {
+		t.Error("Save() without a name succeeded")
+	}
+}
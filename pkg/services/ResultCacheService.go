@@ -0,0 +1,112 @@
+package services
+
+import (
+	"container/list"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/jroden2/holmes-go/pkg/domain"
+	"github.com/rs/zerolog"
+)
+
+// resultOverhead approximates the memory of a cached result, and of each of
+// its rows, beyond the text they hold.
+const resultOverhead = 256
+
+type cachedResult struct {
+	key    string
+	result domain.PageData
+	size   int
+}
+
+type resultCacheService struct {
+	logger   *zerolog.Logger
+	maxBytes int
+	mu       sync.Mutex
+	size     int
+	order    *list.List // most recently used first
+	entries  map[string]*list.Element
+}
+
+// NewResultCacheService keeps comparison results up to about maxBytes in
+// total, evicting the least recently used first.
+func NewResultCacheService(logger *zerolog.Logger, maxBytes int) ResultCacheService {
+	return &resultCacheService{
+		logger:   logger,
+		maxBytes: maxBytes,
+		order:    list.New(),
+		entries:  map[string]*list.Element{},
+	}
+}
+
+type ResultCacheService interface {
+	Get(key string) (domain.PageData, bool)
+	Add(key string, result domain.PageData)
+}
+
+// DefaultResultCacheSize returns $ResultCacheMB megabytes, or 64MB. 0
+// disables the cache.
+func DefaultResultCacheSize() int {
+	if mb, err := strconv.Atoi(os.Getenv("ResultCacheMB")); err == nil && mb >= 0 {
+		return mb << 20
+	}
+	return 64 << 20
+}
+
+func (s *resultCacheService) Get(key string) (domain.PageData, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	el, ok := s.entries[key]
+	if !ok {
+		return domain.PageData{}, false
+	}
+	s.order.MoveToFront(el)
+	return el.Value.(*cachedResult).result, true
+}
+
+// Add stores result under key. Results larger than the whole cache are not
+// kept.
+func (s *resultCacheService) Add(key string, result domain.PageData) {
+	size := resultSize(result)
+	if size > s.maxBytes {
+		s.logger.Debug().Int("size", size).Msg("Result too large to cache")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if el, ok := s.entries[key]; ok {
+		s.remove(el)
+	}
+	s.entries[key] = s.order.PushFront(&cachedResult{key: key, result: result, size: size})
+	s.size += size
+	for s.size > s.maxBytes {
+		s.remove(s.order.Back())
+	}
+}
+
+// remove drops el. s.mu must be held.
+func (s *resultCacheService) remove(el *list.Element) {
+	entry := s.order.Remove(el).(*cachedResult)
+	delete(s.entries, entry.key)
+	s.size -= entry.size
+}
+
+// resultSize estimates the memory held by result.
+func resultSize(result domain.PageData) int {
+	n := resultOverhead + len(result.Error) + len(result.DetectWarning)
+	for _, row := range result.LineDiff {
+		n += resultOverhead + len(row.A) + len(row.B) + len(row.AHTML) + len(row.BHTML)
+	}
+	for _, c := range result.Changes {
+		n += resultOverhead + len(c.Path) + len(c.A) + len(c.B)
+	}
+	for _, issue := range result.CharIssues {
+		n += resultOverhead + len(issue.AAt) + len(issue.BAt)
+	}
+	if img := result.Image; img != nil {
+		n += len(img.A) + len(img.B) + len(img.Mask) + len(img.Overlay) + resultOverhead*len(img.Boxes)
+	}
+	return n
+}
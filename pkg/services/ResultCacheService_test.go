@@ -0,0 +1,74 @@
+package services
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/jroden2/holmes-go/pkg/domain"
+	"github.com/rs/zerolog"
+)
+
+// resultOfSize returns a result whose estimated size is n bytes.
+func resultOfSize(n int) domain.PageData {
+	return domain.PageData{Error: strings.Repeat("x", n-resultOverhead)}
+}
+
+func TestResultCacheService(t *testing.T) {
+	logger := zerolog.New(io.Discard)
+
+	t.Run("evicts least recently used", func(t *testing.T) {
+		s := NewResultCacheService(&logger, 3000)
+		s.Add("a", resultOfSize(1000))
+		s.Add("b", resultOfSize(1000))
+		s.Add("c", resultOfSize(1000))
+		if _, ok := s.Get("a"); !ok {
+			t.Fatal("Get(a) missed before the cache was full")
+		}
+
+		// b is now the least recently used
+		s.Add("d", resultOfSize(1000))
+		for key, want := range map[string]bool{"a": true, "b": false, "c": true, "d": true} {
+			if _, ok := s.Get(key); ok != want {
+				t.Errorf("Get(%s) found = %v, want %v", key, ok, want)
+			}
+		}
+	})
+
+	t.Run("evicts until the result fits", func(t *testing.T) {
+		s := NewResultCacheService(&logger, 3000)
+		s.Add("a", resultOfSize(1000))
+		s.Add("b", resultOfSize(1000))
+		s.Add("c", resultOfSize(2500))
+		for key, want := range map[string]bool{"a": false, "b": false, "c": true} {
+			if _, ok := s.Get(key); ok != want {
+				t.Errorf("Get(%s) found = %v, want %v", key, ok, want)
+			}
+		}
+	})
+
+	t.Run("replaces and skips oversized results", func(t *testing.T) {
+		s := NewResultCacheService(&logger, 3000)
+		s.Add("a", domain.PageData{Error: "first"})
+		s.Add("a", domain.PageData{Error: "second"})
+		if res, ok := s.Get("a"); !ok || res.Error != "second" {
+			t.Errorf("Get(a) = %q, %v, want second", res.Error, ok)
+		}
+
+		s.Add("big", resultOfSize(3001))
+		if _, ok := s.Get("big"); ok {
+			t.Error("a result larger than the cache was kept")
+		}
+		if _, ok := s.Get("a"); !ok {
+			t.Error("adding an oversized result evicted a")
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		s := NewResultCacheService(&logger, 0)
+		s.Add("a", domain.PageData{})
+		if _, ok := s.Get("a"); ok {
+			t.Error("Get() found a result in a disabled cache")
+		}
+	})
+}
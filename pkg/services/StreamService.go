@@ -0,0 +1,143 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/jroden2/holmes-go/pkg/domain"
+	"github.com/jroden2/holmes-go/pkg/utils"
+	"github.com/rs/zerolog"
+)
+
+const (
+	// maxStreams bounds the streamed comparisons kept on disk; the oldest
+	// finished one is removed first.
+	maxStreams = 4
+	// streamRetention is how long a streamed comparison is kept.
+	streamRetention = time.Hour
+)
+
+var ErrTooManyStreams = fmt.Errorf("more than %d large comparisons are in progress", maxStreams)
+
+type stream struct {
+	diff    *domain.StreamDiff
+	created time.Time
+}
+
+type streamService struct {
+	logger  *zerolog.Logger
+	dir     string
+	mu      sync.Mutex
+	streams map[string]*stream
+}
+
+// NewStreamService keeps the files of streamed comparisons in directories
+// under dir.
+func NewStreamService(logger *zerolog.Logger, dir string) StreamService {
+	return &streamService{
+		logger:  logger,
+		dir:     dir,
+		streams: map[string]*stream{},
+	}
+}
+
+type StreamService interface {
+	Create() (string, error)
+	Path(id, side string) string
+	Save(diff domain.StreamDiff)
+	Get(id string) (domain.StreamDiff, bool)
+	Delete(id string)
+}
+
+// DefaultStreamDir returns $StreamDir, or holmes-streams in the temporary
+// directory.
+func DefaultStreamDir() string {
+	if dir := os.Getenv("StreamDir"); dir != "" {
+		return dir
+	}
+	return filepath.Join(os.TempDir(), "holmes-streams")
+}
+
+// Create makes the directory for a new comparison and returns its ID,
+// removing expired comparisons and the oldest finished ones beyond the limit.
+// When every comparison is still in progress it returns ErrTooManyStreams.
+func (s *streamService) Create() (string, error) {
+	id, err := utils.Generate32CharString()
+	if err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for sid, st := range s.streams {
+		if time.Since(st.created) > streamRetention {
+			s.remove(sid)
+		}
+	}
+	for len(s.streams) >= maxStreams {
+		oldest := s.oldestFinished()
+		if oldest == "" {
+			return "", ErrTooManyStreams
+		}
+		s.remove(oldest)
+	}
+	if err := os.MkdirAll(filepath.Join(s.dir, id), 0o700); err != nil {
+		return "", err
+	}
+	s.streams[id] = &stream{created: time.Now()}
+	return id, nil
+}
+
+// Path returns the file holding side "a" or "b" of comparison id.
+func (s *streamService) Path(id, side string) string {
+	return filepath.Join(s.dir, id, side)
+}
+
+// Save records the result of a comparison made with Create.
+func (s *streamService) Save(diff domain.StreamDiff) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if st, ok := s.streams[diff.ID]; ok {
+		st.diff = &diff
+	}
+}
+
+func (s *streamService) Get(id string) (domain.StreamDiff, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, ok := s.streams[id]
+	if !ok || st.diff == nil || time.Since(st.created) > streamRetention {
+		return domain.StreamDiff{}, false
+	}
+	return *st.diff, true
+}
+
+func (s *streamService) Delete(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.remove(id)
+}
+
+// remove forgets id and deletes its files. s.mu must be held.
+func (s *streamService) remove(id string) {
+	delete(s.streams, id)
+	if err := os.RemoveAll(filepath.Join(s.dir, id)); err != nil {
+		s.logger.Error().Err(err).Str("stream", id).Msg("Failed to remove stream files")
+	}
+}
+
+// oldestFinished returns the ID of the oldest comparison that has a result,
+// or "" when none has. s.mu must be held.
+func (s *streamService) oldestFinished() string {
+	var id string
+	var created time.Time
+	for sid, st := range s.streams {
+		if st.diff != nil && (id == "" || st.created.Before(created)) {
+			id, created = sid, st.created
+		}
+	}
+	return id
+}
@@ -0,0 +1,49 @@
+package services
+
+import (
+	"errors"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/jroden2/holmes-go/pkg/domain"
+	"github.com/rs/zerolog"
+)
+
+func TestStreamService(t *testing.T) {
+	logger := zerolog.New(io.Discard)
+
+	t.Run("evicts only finished streams", func(t *testing.T) {
+		s := NewStreamService(&logger, t.TempDir())
+		var ids []string
+		for range maxStreams {
+			id, err := s.Create()
+			if err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+			ids = append(ids, id)
+		}
+
+		// Every stream is still being uploaded
+		if _, err := s.Create(); !errors.Is(err, ErrTooManyStreams) {
+			t.Fatalf("Create() error = %v, want ErrTooManyStreams", err)
+		}
+
+		s.Save(domain.StreamDiff{ID: ids[1]})
+		id, err := s.Create()
+		if err != nil {
+			t.Fatalf("Create() after a stream finished error = %v", err)
+		}
+		if _, ok := s.Get(ids[1]); ok {
+			t.Error("the finished stream was kept")
+		}
+		if _, err := os.Stat(s.Path(ids[1], "")); !os.IsNotExist(err) {
+			t.Errorf("the finished stream's files were kept: %v", err)
+		}
+		for _, id := range append([]string{ids[0], ids[2], ids[3]}, id) {
+			if _, err := os.Stat(s.Path(id, "")); err != nil {
+				t.Errorf("stream %s in progress was removed: %v", id, err)
+			}
+		}
+	})
+}
@@ -0,0 +1,228 @@
+package utils
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/jroden2/holmes-go/pkg/domain"
+)
+
+// maxArchiveEntries bounds how many files are read from one archive.
+const maxArchiveEntries = 10000
+
+// IsArchive reports whether s is a zip, tar or gzipped tar archive.
+func IsArchive(s string) bool {
+	switch {
+	case strings.HasPrefix(s, "PK\x03\x04"), strings.HasPrefix(s, "PK\x05\x06"):
+		return true
+	case isGzip([]byte(s)):
+		zr, err := gzip.NewReader(strings.NewReader(s))
+		if err != nil {
+			return false
+		}
+		defer zr.Close()
+		head := make([]byte, 512)
+		n, _ := io.ReadFull(zr, head)
+		return isTarHeader(head[:n])
+	default:
+		return isTarHeader([]byte(s))
+	}
+}
+
+func isTarHeader(b []byte) bool {
+	return len(b) >= 262 && string(b[257:262]) == "ustar"
+}
+
+// ReadArchive returns the regular files in a zip, tar or tar.gz archive keyed
+// by slash separated path. A single top-level directory shared by every entry
+// (e.g. "app_1.2.0_linux_amd64/") is stripped so builds of different
+// versions line up.
+func ReadArchive(s string) (map[string][]byte, error) {
+	var files map[string][]byte
+	var err error
+	switch {
+	case strings.HasPrefix(s, "PK"):
+		files, err = readZip(s)
+	case isGzip([]byte(s)):
+		zr, gzErr := gzip.NewReader(strings.NewReader(s))
+		if gzErr != nil {
+			return nil, gzErr
+		}
+		defer zr.Close()
+		files, err = readTar(zr)
+	default:
+		files, err = readTar(strings.NewReader(s))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return stripCommonRoot(files), nil
+}
+
+func readZip(s string) (map[string][]byte, error) {
+	zr, err := zip.NewReader(strings.NewReader(s), int64(len(s)))
+	if err != nil {
+		return nil, err
+	}
+
+	files := map[string][]byte{}
+	total := 0
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		if len(files) >= maxArchiveEntries {
+			return nil, fmt.Errorf("more than %d entries", maxArchiveEntries)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f.Name, err)
+		}
+		b, err := readArchiveEntry(rc, &total)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f.Name, err)
+		}
+		files[cleanEntryPath(f.Name)] = b
+	}
+	return files, nil
+}
+
+func readTar(r io.Reader) (map[string][]byte, error) {
+	tr := tar.NewReader(r)
+	files := map[string][]byte{}
+	total := 0
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return files, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		if len(files) >= maxArchiveEntries {
+			return nil, fmt.Errorf("more than %d entries", maxArchiveEntries)
+		}
+		b, err := readArchiveEntry(tr, &total)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", hdr.Name, err)
+		}
+		files[cleanEntryPath(hdr.Name)] = b
+	}
+}
+
+// readArchiveEntry reads one entry, keeping the total size of a set of files
+// within maxDecodedSize.
+func readArchiveEntry(r io.Reader, total *int) ([]byte, error) {
+	b, err := io.ReadAll(io.LimitReader(r, int64(maxDecodedSize-*total)+1))
+	if err != nil {
+		return nil, err
+	}
+	*total += len(b)
+	if *total > maxDecodedSize {
+		return nil, fmt.Errorf("files total more than %d bytes", maxDecodedSize)
+	}
+	return b, nil
+}
+
+func cleanEntryPath(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
+
+func stripCommonRoot(files map[string][]byte) map[string][]byte {
+	root := ""
+	for name := range files {
+		dir, _, ok := strings.Cut(name, "/")
+		if !ok || (root != "" && dir != root) {
+			return files
+		}
+		root = dir
+	}
+	if root == "" {
+		return files
+	}
+
+	out := make(map[string][]byte, len(files))
+	for name, b := range files {
+		out[strings.TrimPrefix(name, root+"/")] = b
+	}
+	return out
+}
+
+// DiffFileSets pairs files by path and returns a tree of entries, with a row
+// for each directory, marking files added, removed or changed (by size and
+// SHA-256).
+func DiffFileSets(a, b map[string][]byte) []domain.FileEntry {
+	paths := make([]string, 0, len(a)+len(b))
+	for p := range a {
+		paths = append(paths, p)
+	}
+	for p := range b {
+		if _, ok := a[p]; !ok {
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+
+	var out []domain.FileEntry
+	dirIndex := map[string]int{}
+	for _, p := range paths {
+		// Directory rows for any parents not yet listed
+		parts := strings.Split(p, "/")
+		for d := 1; d < len(parts); d++ {
+			dir := strings.Join(parts[:d], "/") + "/"
+			if _, ok := dirIndex[dir]; !ok {
+				dirIndex[dir] = len(out)
+				out = append(out, domain.FileEntry{Path: dir, Name: parts[d-1] + "/", Depth: d - 1, Dir: true, Status: "identical"})
+			}
+		}
+
+		ba, inA := a[p]
+		bb, inB := b[p]
+		e := domain.FileEntry{Path: p, Name: parts[len(parts)-1], Depth: len(parts) - 1, ASize: -1, BSize: -1}
+		if inA {
+			e.ASize, e.AHash = len(ba), Sha256Hex(string(ba))
+		}
+		if inB {
+			e.BSize, e.BHash = len(bb), Sha256Hex(string(bb))
+		}
+		switch {
+		case !inB:
+			e.Status = "removed"
+		case !inA:
+			e.Status = "added"
+		case e.AHash != e.BHash:
+			e.Status = "changed"
+		default:
+			e.Status = "identical"
+		}
+		out = append(out, e)
+
+		// A directory is changed if anything below it is
+		if e.Status != "identical" {
+			for d := 1; d < len(parts); d++ {
+				out[dirIndex[strings.Join(parts[:d], "/")+"/"]].Status = "changed"
+			}
+		}
+	}
+	return out
+}
+
+// FileSetSize sums the size of every file in files.
+func FileSetSize(files map[string][]byte) int {
+	n := 0
+	for _, b := range files {
+		n += len(b)
+	}
+	return n
+}
@@ -0,0 +1,138 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"unicode/utf8"
+
+	"github.com/jroden2/holmes-go/pkg/domain"
+)
+
+// hexDumpWidth is the number of bytes shown per hex dump row.
+const hexDumpWidth = 16
+
+// maxHexDumpRows caps the rendered dump (64KB); byte counts still cover the
+// whole input.
+const maxHexDumpRows = 4096
+
+// binarySniffLen matches the prefix git inspects when guessing binary files.
+const binarySniffLen = 8000
+
+// IsBinary reports whether s looks like binary data rather than text: it
+// contains a NUL byte or is not valid UTF-8 near the start.
+func IsBinary(s string) bool {
+	if len(s) > binarySniffLen {
+		s = s[:binarySniffLen]
+	}
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == 0:
+			return true
+		case r == utf8.RuneError && size == 1:
+			// A rune cut off by the sniff limit is not evidence of binary
+			return utf8.FullRuneInString(s[i:])
+		}
+		i += size
+	}
+	return false
+}
+
+// HexDumpDiff renders a and b as aligned hex/ASCII dumps with differing bytes
+// highlighted. It also returns the number of differing bytes (a length
+// difference counts every extra byte) and the offset of the first difference,
+// or -1 when the inputs are identical.
+func HexDumpDiff(a, b string) ([]domain.LineDiffRow, int, int) {
+	n := max(len(a), len(b))
+
+	diffBytes, firstDiff := 0, -1
+	for i := 0; i < n; i++ {
+		if i >= len(a) || i >= len(b) || a[i] != b[i] {
+			diffBytes++
+			if firstDiff < 0 {
+				firstDiff = i
+			}
+		}
+	}
+
+	rows := (n + hexDumpWidth - 1) / hexDumpWidth
+	out := make([]domain.LineDiffRow, 0, min(rows, maxHexDumpRows))
+	for r := 0; r < rows && r < maxHexDumpRows; r++ {
+		off := r * hexDumpWidth
+		hasA, hasB := off < len(a), off < len(b)
+
+		status := "same"
+		switch {
+		case hasA && !hasB:
+			status = "removed"
+		case !hasA && hasB:
+			status = "added"
+		case rowSlice(a, off) != rowSlice(b, off):
+			status = "changed"
+		}
+
+		row := domain.LineDiffRow{LineNum: r + 1, Status: status}
+		if hasA {
+			row.AHTML = hexDumpRow(a, b, off)
+		}
+		if hasB {
+			row.BHTML = hexDumpRow(b, a, off)
+		}
+		out = append(out, row)
+	}
+	return out, diffBytes, firstDiff
+}
+
+func rowSlice(s string, off int) string {
+	if off >= len(s) {
+		return ""
+	}
+	return s[off:min(off+hexDumpWidth, len(s))]
+}
+
+// hexDumpRow writes one row of s starting at off, marking bytes that differ
+// from other (or are missing from it).
+func hexDumpRow(s, other string, off int) template.HTML {
+	differs := func(i int) bool {
+		return i >= len(other) || s[i] != other[i]
+	}
+
+	var hexPart, asciiPart bytes.Buffer
+	inMark := false
+	for i := off; i < off+hexDumpWidth; i++ {
+		if i >= len(s) {
+			if inMark {
+				hexPart.WriteString("</mark>")
+				asciiPart.WriteString("</mark>")
+				inMark = false
+			}
+			hexPart.WriteString("   ")
+			continue
+		}
+
+		d := differs(i)
+		if d && !inMark {
+			hexPart.WriteString("<mark>")
+			asciiPart.WriteString("<mark>")
+			inMark = true
+		} else if !d && inMark {
+			hexPart.WriteString("</mark>")
+			asciiPart.WriteString("</mark>")
+			inMark = false
+		}
+
+		fmt.Fprintf(&hexPart, "%02x ", s[i])
+		if c := s[i]; c >= 0x20 && c < 0x7f {
+			asciiPart.WriteString(template.HTMLEscapeString(string(c)))
+		} else {
+			asciiPart.WriteByte('.')
+		}
+	}
+	if inMark {
+		hexPart.WriteString("</mark>")
+		asciiPart.WriteString("</mark>")
+	}
+
+	return template.HTML(fmt.Sprintf(`<span class="text-muted">%08x</span>  %s |%s|`, off, hexPart.String(), asciiPart.String()))
+}
@@ -0,0 +1,220 @@
+package utils
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/jroden2/holmes-go/pkg/domain"
+)
+
+// csvDelimiters are tried in order when sniffing the delimiter.
+var csvDelimiters = []rune{',', '\t', ';', '|'}
+
+// csvSniffLines is how many leading lines are inspected to guess the
+// delimiter.
+const csvSniffLines = 20
+
+// csvTable is a parsed CSV file with its header row split off.
+type csvTable struct {
+	header []string
+	rows   [][]string
+	lines  []int // source line of each row
+}
+
+// PrettyCSV re-writes s comma separated with consistent quoting and fields
+// trimmed of surrounding whitespace.
+func PrettyCSV(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return "", nil
+	}
+
+	records, _, err := parseCSV(s)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	w := csv.NewWriter(&sb)
+	if err := w.WriteAll(records); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+// DiffCSV compares two CSV files with a header row. Columns are matched by
+// name and rows by their first column when it is a unique key in both files,
+// otherwise by position.
+func DiffCSV(a, b string) ([]domain.PathChange, error) {
+	ta, err := parseCSVTable(a)
+	if err != nil {
+		return nil, err
+	}
+	tb, err := parseCSVTable(b)
+	if err != nil {
+		return nil, err
+	}
+
+	out := []domain.PathChange{}
+	colsA, colsB := toSet(ta.header...), toSet(tb.header...)
+	var common []string
+	for _, name := range unionKeys(ta.header, tb.header) {
+		switch {
+		case colsA[name] && !colsB[name]:
+			out = append(out, domain.PathChange{Path: "column " + name, A: name, Status: "removed"})
+		case !colsA[name] && colsB[name]:
+			out = append(out, domain.PathChange{Path: "column " + name, B: name, Status: "added"})
+		default:
+			common = append(common, name)
+		}
+	}
+
+	keysA, keysB, keyed := csvRowKeys(ta, tb)
+	indexB := make(map[string]int, len(keysB))
+	for i, k := range keysB {
+		indexB[k] = i
+	}
+	indexA := make(map[string]int, len(keysA))
+	for i, k := range keysA {
+		indexA[k] = i
+	}
+
+	rowPath := func(t *csvTable, i int, key string) string {
+		if keyed {
+			return "row " + t.header[0] + "=" + key
+		}
+		return "row " + strconv.Itoa(t.lines[i])
+	}
+
+	for i, key := range keysA {
+		j, ok := indexB[key]
+		if !ok {
+			out = append(out, domain.PathChange{Path: rowPath(ta, i, key), A: strings.Join(ta.rows[i], ","), Status: "removed"})
+			continue
+		}
+		for _, col := range common {
+			va, vb := ta.cell(i, col), tb.cell(j, col)
+			if va != vb {
+				out = append(out, domain.PathChange{Path: rowPath(ta, i, key) + " > " + col, A: va, B: vb, Status: "changed"})
+			}
+		}
+	}
+	for j, key := range keysB {
+		if _, ok := indexA[key]; !ok {
+			out = append(out, domain.PathChange{Path: rowPath(tb, j, key), B: strings.Join(tb.rows[j], ","), Status: "added"})
+		}
+	}
+	return out, nil
+}
+
+// SniffCSVDelimiter reports the delimiter that splits the first lines of s
+// into the same number (at least two) of fields.
+func SniffCSVDelimiter(s string) (rune, bool) {
+	lines := strings.SplitN(strings.TrimSpace(s), "\n", csvSniffLines+1)
+	if len(lines) > csvSniffLines {
+		lines = lines[:csvSniffLines]
+	}
+	sample := strings.Join(lines, "\n")
+
+	for _, d := range csvDelimiters {
+		r := csv.NewReader(strings.NewReader(sample))
+		r.Comma = d
+		r.LazyQuotes = true
+		records, err := r.ReadAll()
+		if err == nil && len(records) >= 2 && len(records[0]) >= 2 {
+			return d, true
+		}
+	}
+	return 0, false
+}
+
+// parseCSV reads every record of s using the sniffed delimiter (comma by
+// default) and also returns the source line of each record.
+func parseCSV(s string) ([][]string, []int, error) {
+	r := csv.NewReader(strings.NewReader(s))
+	if d, ok := SniffCSVDelimiter(s); ok {
+		r.Comma = d
+	}
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	r.TrimLeadingSpace = true
+
+	var records [][]string
+	var lines []int
+	for {
+		rec, err := r.Read()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return records, lines, nil
+			}
+			return nil, nil, err
+		}
+		for i := range rec {
+			rec[i] = strings.TrimSpace(rec[i])
+		}
+		line, _ := r.FieldPos(0)
+		records = append(records, rec)
+		lines = append(lines, line)
+	}
+}
+
+func parseCSVTable(s string) (*csvTable, error) {
+	records, lines, err := parseCSV(strings.TrimSpace(s))
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return &csvTable{}, nil
+	}
+	return &csvTable{header: records[0], rows: records[1:], lines: lines[1:]}, nil
+}
+
+// cell returns the value of column col in row i, or "" when the row is short.
+func (t *csvTable) cell(i int, col string) string {
+	for c, name := range t.header {
+		if name == col {
+			if c < len(t.rows[i]) {
+				return t.rows[i][c]
+			}
+			return ""
+		}
+	}
+	return ""
+}
+
+// csvRowKeys returns the key of each row in a and b: the first column when
+// both files share it and its values are unique, otherwise the row position.
+func csvRowKeys(a, b *csvTable) ([]string, []string, bool) {
+	firstCol := func(t *csvTable) ([]string, bool) {
+		keys := make([]string, len(t.rows))
+		seen := make(map[string]bool, len(t.rows))
+		for i, row := range t.rows {
+			if len(row) == 0 || row[0] == "" || seen[row[0]] {
+				return nil, false
+			}
+			keys[i] = row[0]
+			seen[row[0]] = true
+		}
+		return keys, true
+	}
+
+	if len(a.header) > 0 && len(b.header) > 0 && a.header[0] == b.header[0] {
+		ka, okA := firstCol(a)
+		kb, okB := firstCol(b)
+		if okA && okB {
+			return ka, kb, true
+		}
+	}
+
+	position := func(t *csvTable) []string {
+		keys := make([]string, len(t.rows))
+		for i := range keys {
+			keys[i] = strconv.Itoa(i)
+		}
+		return keys
+	}
+	return position(a), position(b), false
+}
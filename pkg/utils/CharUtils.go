@@ -0,0 +1,229 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"strings"
+	"unicode"
+
+	"github.com/jroden2/holmes-go/pkg/domain"
+)
+
+// maxCharIssueExamples bounds the locations listed per issue and side.
+const maxCharIssueExamples = 3
+
+// confusables maps non-Latin letters that render like ASCII letters to the
+// letter they imitate. Fullwidth forms are handled separately.
+var confusables = map[rune]rune{
+	// Cyrillic
+	'\u0430': 'a', '\u0435': 'e', '\u043e': 'o', '\u0440': 'p', '\u0441': 'c', '\u0443': 'y',
+	'\u0445': 'x', '\u0456': 'i', '\u0458': 'j', '\u0455': 's', '\u0501': 'd', '\u04bb': 'h',
+	'\u051b': 'q', '\u051d': 'w', '\u0410': 'A', '\u0412': 'B', '\u0415': 'E', '\u041a': 'K',
+	'\u041c': 'M', '\u041d': 'H', '\u041e': 'O', '\u0420': 'P', '\u0421': 'C', '\u0422': 'T',
+	'\u0425': 'X', '\u0406': 'I', '\u0408': 'J', '\u0405': 'S',
+	// Greek
+	'\u03bf': 'o', '\u03bd': 'v', '\u03c1': 'p', '\u03b9': 'i', '\u0391': 'A', '\u0392': 'B',
+	'\u0395': 'E', '\u0396': 'Z', '\u0397': 'H', '\u0399': 'I', '\u039a': 'K', '\u039c': 'M',
+	'\u039d': 'N', '\u039f': 'O', '\u03a1': 'P', '\u03a4': 'T', '\u03a5': 'Y', '\u03a7': 'X',
+}
+
+// charIssueKinds lists the report rows in display order.
+var charIssueKinds = []string{
+	"CRLF line endings",
+	"LF line endings",
+	"CR line endings",
+	"Trailing newline",
+	"Tab indentation",
+	"Space indentation",
+	"Byte order mark",
+	"Zero-width characters",
+	"Non-breaking spaces",
+	"Bidi control characters",
+	"Confusable characters",
+}
+
+// layoutKinds are only worth reporting when one input has them and the other
+// does not, e.g. CRLF on one side only. Other kinds are always reported.
+var layoutKinds = map[string]bool{
+	"CRLF line endings": true,
+	"LF line endings":   true,
+	"Trailing newline":  true,
+	"Tab indentation":   true,
+	"Space indentation": true,
+}
+
+// charStats collects occurrences and example locations per issue kind.
+type charStats struct {
+	count    map[string]int
+	examples map[string][]string
+}
+
+func (s *charStats) add(kind, example string) {
+	s.count[kind]++
+	if len(s.examples[kind]) < maxCharIssueExamples && example != "" {
+		s.examples[kind] = append(s.examples[kind], example)
+	}
+}
+
+// InspectChars reports line endings, indentation and invisible or
+// look-alike characters in a and b. Layout differences are only returned when
+// the two inputs disagree.
+func InspectChars(a, b string) []domain.CharIssue {
+	sa, sb := inspectChars(a), inspectChars(b)
+
+	var out []domain.CharIssue
+	for _, kind := range charIssueKinds {
+		inA, inB := sa.count[kind] > 0, sb.count[kind] > 0
+		if !inA && !inB || layoutKinds[kind] && inA == inB {
+			continue
+		}
+		out = append(out, domain.CharIssue{
+			Kind: kind,
+			A:    sa.count[kind],
+			B:    sb.count[kind],
+			AAt:  strings.Join(sa.examples[kind], "; "),
+			BAt:  strings.Join(sb.examples[kind], "; "),
+		})
+	}
+	return out
+}
+
+func inspectChars(s string) *charStats {
+	st := &charStats{count: map[string]int{}, examples: map[string][]string{}}
+	if s == "" {
+		return st
+	}
+
+	if strings.HasPrefix(s, "\ufeff") {
+		st.add("Byte order mark", "start of input")
+	}
+	if strings.HasSuffix(s, "\n") || strings.HasSuffix(s, "\r") {
+		st.add("Trailing newline", "")
+	}
+
+	line := 1
+	at := func() string { return fmt.Sprintf("line %d", line) }
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\r':
+			if i+1 < len(s) && s[i+1] == '\n' {
+				st.add("CRLF line endings", at())
+				i++
+			} else {
+				st.add("CR line endings", at())
+			}
+			line++
+		case '\n':
+			st.add("LF line endings", at())
+			line++
+		}
+	}
+
+	for n, text := range strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n") {
+		line = n + 1
+		switch {
+		case strings.HasPrefix(text, "\t"):
+			st.add("Tab indentation", at())
+		case strings.HasPrefix(text, " "):
+			st.add("Space indentation", at())
+		}
+
+		for _, word := range strings.FieldsFunc(text, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		}) {
+			mixed := strings.ContainsFunc(word, func(r rune) bool {
+				return r < unicode.MaxASCII && unicode.IsLetter(r)
+			})
+			for _, r := range word {
+				if look, ok := confusableOf(r, mixed); ok {
+					st.add("Confusable characters", fmt.Sprintf("line %d: %q (%U) looks like %q in %q", line, r, r, look, word))
+				}
+			}
+		}
+
+		for col, r := range []rune(text) {
+			if kind := invisibleKind(r); kind != "" && !(r == '\ufeff' && line == 1 && col == 0) {
+				st.add(kind, fmt.Sprintf("line %d col %d (%U)", line, col+1, r))
+			}
+		}
+	}
+	return st
+}
+
+// confusableOf returns the ASCII letter r imitates. Script look-alikes only
+// count inside words that also use Latin letters, so ordinary Cyrillic or
+// Greek text is not flagged; fullwidth forms always count.
+func confusableOf(r rune, mixed bool) (rune, bool) {
+	if r >= 0xff01 && r <= 0xff5e {
+		return r - 0xfee0, true
+	}
+	look, ok := confusables[r]
+	return look, ok && mixed
+}
+
+// invisibleKind classifies characters that render as nothing or as a plain
+// space.
+func invisibleKind(r rune) string {
+	switch r {
+	case '\u200b', '\u200c', '\u200d', '\u2060', '\ufeff':
+		return "Zero-width characters"
+	case '\u00a0', '\u2007', '\u202f':
+		return "Non-breaking spaces"
+	case '\u200e', '\u200f', '\u061c', '\u202a', '\u202b', '\u202c', '\u202d', '\u202e',
+		'\u2066', '\u2067', '\u2068', '\u2069':
+		return "Bidi control characters"
+	}
+	return ""
+}
+
+// VisibleLineDiff is BasicLineDiffWithHighlight for whitespace debugging:
+// CRLF is not folded into LF, and tabs, spaces, carriage returns and
+// invisible characters are drawn as visible markers.
+func VisibleLineDiff(a, b string) []domain.LineDiffRow {
+	split := func(s string) []string {
+		if s == "" {
+			return []string{}
+		}
+		return strings.Split(s, "\n")
+	}
+	return lineDiffRows(split(a), split(b), func(av, bv string) bool {
+		return av == bv
+	}, func(av, bv string, changed bool) (template.HTML, template.HTML) {
+		ar, br := []rune(av), []rune(bv)
+		if !changed {
+			return renderVisible(ar, 0, 0), renderVisible(br, 0, 0)
+		}
+		p, as, bs := charDiffBounds(ar, br)
+		return renderVisible(ar, p, as), renderVisible(br, p, bs)
+	})
+}
+
+// renderVisible writes r with markers for whitespace and invisible
+// characters, highlighting the rune range [start, end).
+func renderVisible(r []rune, start, end int) template.HTML {
+	var buf bytes.Buffer
+	for i, c := range r {
+		if i == start && end > start {
+			buf.WriteString("<mark>")
+		}
+		switch {
+		case c == ' ':
+			buf.WriteString(`<span class="ws">·</span>`)
+		case c == '\t':
+			buf.WriteString(`<span class="ws">→</span>` + "\t")
+		case c == '\r':
+			buf.WriteString(`<span class="ws" title="carriage return">␍</span>`)
+		case invisibleKind(c) == "Non-breaking spaces":
+			buf.WriteString(fmt.Sprintf(`<span class="ws invisible-char" title="%U">⍽</span>`, c))
+		case invisibleKind(c) != "":
+			buf.WriteString(fmt.Sprintf(`<span class="ws invisible-char" title="%U">[%U]</span>`, c, c))
+		default:
+			buf.WriteString(template.HTMLEscapeString(string(c)))
+		}
+		if i == end-1 && end > start {
+			buf.WriteString("</mark>")
+		}
+	}
+	return template.HTML(buf.String())
+}
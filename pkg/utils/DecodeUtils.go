@@ -0,0 +1,219 @@
+package utils
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxDecodeLayers bounds auto-detection so pathological input cannot loop.
+const maxDecodeLayers = 8
+
+// maxDecodedSize caps decompressed output, matching the upload limit.
+const maxDecodedSize = 16 << 20
+
+var errDecodedTooLarge = fmt.Errorf("decompressed content is larger than %d bytes", maxDecodedSize)
+
+var (
+	base64Pattern    = regexp.MustCompile(`^[A-Za-z0-9+/]+={0,2}$`)
+	base64URLPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+={0,2}$`)
+	hexPattern       = regexp.MustCompile(`^(?:[0-9a-fA-F]{2})+$`)
+	percentPattern   = regexp.MustCompile(`%[0-9a-fA-F]{2}`)
+)
+
+// decoders are tried in this order during auto-detection: binary containers
+// first, then text encodings.
+var decoders = []struct {
+	name   string
+	detect func(b []byte) bool
+	decode func(b []byte) ([]byte, error)
+}{
+	{"gzip", isGzip, gunzip},
+	{"zlib", isZlib, unzlib},
+	{"hex", isHexText, decodeHex},
+	{"base64", isBase64Text, decodeBase64},
+	{"base64url", isBase64URLText, decodeBase64URL},
+	{"url", isPercentEncoded, decodePercent},
+}
+
+// DecodeLayers peels encodings off s. With chain "auto" each layer is detected
+// until nothing more applies; otherwise chain is a comma separated list of
+// decoder names applied in order, e.g. "base64,gzip". It returns the decoded
+// content and the names of the layers that were removed.
+func DecodeLayers(s, chain string) (string, []string, error) {
+	b := []byte(strings.TrimSpace(s))
+	if len(b) == 0 || chain == "" {
+		return s, nil, nil
+	}
+
+	var applied []string
+	if chain != "auto" {
+		for _, name := range strings.Split(chain, ",") {
+			name = strings.TrimSpace(name)
+			dec, ok := decoderByName(name)
+			if !ok {
+				return "", applied, fmt.Errorf("unknown decoder %q", name)
+			}
+			out, err := dec(b)
+			if err != nil {
+				return "", applied, fmt.Errorf("%s: %w", name, err)
+			}
+			b = out
+			applied = append(applied, name)
+		}
+		return string(b), applied, nil
+	}
+
+	for len(applied) < maxDecodeLayers {
+		decoded := false
+		for _, d := range decoders {
+			if !d.detect(b) {
+				continue
+			}
+			out, err := d.decode(b)
+			if errors.Is(err, errDecodedTooLarge) {
+				return "", applied, fmt.Errorf("%s: %w", d.name, err)
+			}
+			if err != nil || !plausiblyDecoded(out) {
+				continue
+			}
+			b = out
+			applied = append(applied, d.name)
+			decoded = true
+			break
+		}
+		if !decoded {
+			break
+		}
+	}
+	return string(b), applied, nil
+}
+
+func decoderByName(name string) (func([]byte) ([]byte, error), bool) {
+	for _, d := range decoders {
+		if d.name == name {
+			return d.decode, true
+		}
+	}
+	return nil, false
+}
+
+// plausiblyDecoded rejects decodes that turned text into noise, e.g. a plain
+// word that happens to be valid base64. Compressed data is always accepted
+// since it is peeled off next.
+func plausiblyDecoded(b []byte) bool {
+	if len(b) == 0 {
+		return false
+	}
+	if isGzip(b) || isZlib(b) || json.Valid(b) {
+		return true
+	}
+	if !utf8.Valid(b) {
+		return false
+	}
+	printable := 0
+	for _, r := range string(b) {
+		if unicode.IsPrint(r) || unicode.IsSpace(r) {
+			printable++
+		}
+	}
+	return printable*100/utf8.RuneCount(b) >= 95
+}
+
+func isGzip(b []byte) bool {
+	return len(b) > 2 && b[0] == 0x1f && b[1] == 0x8b
+}
+
+func isZlib(b []byte) bool {
+	// CMF must be deflate and the header checksum must hold
+	return len(b) > 2 && b[0]&0x0f == 8 && (uint16(b[0])<<8|uint16(b[1]))%31 == 0
+}
+
+func isHexText(b []byte) bool {
+	return len(b) >= 8 && hexPattern.Match(b)
+}
+
+func isBase64Text(b []byte) bool {
+	s := stripSpace(b)
+	return len(s) >= 8 && base64Pattern.MatchString(s)
+}
+
+func isBase64URLText(b []byte) bool {
+	s := stripSpace(b)
+	return len(s) >= 8 && base64URLPattern.MatchString(s)
+}
+
+func isPercentEncoded(b []byte) bool {
+	return percentPattern.Match(b)
+}
+
+func gunzip(b []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return readDecompressed(r)
+}
+
+func unzlib(b []byte) ([]byte, error) {
+	r, err := zlib.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return readDecompressed(r)
+}
+
+// readDecompressed reads r, failing rather than truncating once it passes
+// maxDecodedSize.
+func readDecompressed(r io.Reader) ([]byte, error) {
+	b, err := io.ReadAll(io.LimitReader(r, maxDecodedSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(b) > maxDecodedSize {
+		return nil, errDecodedTooLarge
+	}
+	return b, nil
+}
+
+func decodeHex(b []byte) ([]byte, error) {
+	return hex.DecodeString(string(bytes.TrimSpace(b)))
+}
+
+func decodeBase64(b []byte) ([]byte, error) {
+	s := strings.TrimRight(stripSpace(b), "=")
+	return base64.RawStdEncoding.DecodeString(s)
+}
+
+func decodeBase64URL(b []byte) ([]byte, error) {
+	s := strings.TrimRight(stripSpace(b), "=")
+	return base64.RawURLEncoding.DecodeString(s)
+}
+
+// decodePercent uses path unescaping so a literal "+" (common in base64
+// payloads) is kept rather than turned into a space.
+func decodePercent(b []byte) ([]byte, error) {
+	s, err := url.PathUnescape(string(b))
+	if err != nil {
+		return nil, err
+	}
+	return []byte(s), nil
+}
+
+// stripSpace removes line breaks that are common in wrapped base64.
+func stripSpace(b []byte) string {
+	return strings.Join(strings.Fields(string(b)), "")
+}
@@ -0,0 +1,133 @@
+package utils
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"regexp"
+	"strings"
+)
+
+var (
+	sniffJWT     = regexp.MustCompile(`^(Bearer\s+)?eyJ[\w-]*\.[\w-]+\.[\w-]*$`)
+	sniffGo      = regexp.MustCompile(`(?m)^package\s+\w+\s*$`)
+	sniffHTML    = regexp.MustCompile(`(?i)^(<!--.*?-->\s*)*<(!doctype\s+html|html[\s>])`)
+	sniffSQL     = regexp.MustCompile(`(?i)^(--[^\n]*\n\s*)*(select|insert|update|delete|create|alter|drop|with)\s`)
+	sniffYAMLKey = regexp.MustCompile(`(?m)^(---\s*$|\s*- |\s*[\w.-]+:(\s|$))`)
+	sniffLogTime = regexp.MustCompile(`^\[?(\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}|\d{2}/[A-Z][a-z]{2}/\d{4}:|(Jan|Feb|Mar|Apr|May|Jun|Jul|Aug|Sep|Oct|Nov|Dec) [ \d]\d \d{2}:)`)
+)
+
+// SniffMode guesses the comparison mode for s from its content, falling back
+// to "text". Cheap, unambiguous checks run before ones that need a parse.
+func SniffMode(s string) string {
+	if IsImage(s) {
+		return "image"
+	}
+	if IsBinary(s) {
+		return "binary"
+	}
+	s = strings.TrimSpace(s)
+	switch {
+	case s == "":
+		return "text"
+	case (s[0] == '{' || s[0] == '[') && json.Valid([]byte(s)):
+		return "json"
+	case sniffJWT.MatchString(s):
+		return "jwt"
+	case httpRequestLine.MatchString(firstLine(s)) || httpStatusLine.MatchString(firstLine(s)):
+		return "http"
+	case sniffHTML.MatchString(s):
+		return "html"
+	case s[0] == '<' && wellFormedXML(s):
+		return "xml"
+	case sniffGo.MatchString(s):
+		return "go"
+	case sniffSQL.MatchString(s):
+		return "sql"
+	case looksLikeLog(s):
+		return "log"
+	case looksLikeYAML(s):
+		return "yaml"
+	case looksLikeCSV(s):
+		return "csv"
+	default:
+		return "text"
+	}
+}
+
+// wellFormedXML reports whether s is a sequence of well-formed XML tokens with
+// at least one element.
+func wellFormedXML(s string) bool {
+	dec := xml.NewDecoder(strings.NewReader(s))
+	elements := 0
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			return elements > 0
+		}
+		if err != nil {
+			return false
+		}
+		if _, ok := tok.(xml.StartElement); ok {
+			elements++
+		}
+	}
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return strings.TrimSpace(line)
+}
+
+// looksLikeLog requires most lines to start with a timestamp.
+func looksLikeLog(s string) bool {
+	lines := SplitLines(s)
+	hits := 0
+	for _, line := range lines {
+		if sniffLogTime.MatchString(line) {
+			hits++
+		}
+	}
+	return hits > 0 && hits*2 >= len(lines)
+}
+
+// looksLikeYAML requires YAML structure (keys, list items or document
+// markers) that parses into a mapping or sequence; any line of prose is
+// valid YAML as a plain scalar.
+func looksLikeYAML(s string) bool {
+	if !sniffYAMLKey.MatchString(s) {
+		return false
+	}
+	docs, err := decodeYAML(s)
+	if err != nil || len(docs) == 0 {
+		return false
+	}
+	for _, doc := range docs {
+		switch doc.(type) {
+		case map[string]any, []any:
+		default:
+			return false
+		}
+	}
+	// Reject "Note: something" style prose that only parses as one key
+	if m, ok := docs[0].(map[string]any); ok && len(docs) == 1 && len(m) == 1 && !strings.Contains(s, "\n") {
+		return false
+	}
+	return true
+}
+
+// looksLikeCSV requires a consistent delimiter and rejects prose, where
+// commas separate clauses that end in sentence punctuation.
+func looksLikeCSV(s string) bool {
+	if _, ok := SniffCSVDelimiter(s); !ok {
+		return false
+	}
+	for _, line := range SplitLines(s) {
+		line = strings.TrimSpace(line)
+		if line != "" && strings.ContainsRune(".?!", rune(line[len(line)-1])) {
+			return false
+		}
+	}
+	return true
+}
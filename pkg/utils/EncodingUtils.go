@@ -0,0 +1,103 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	xunicode "golang.org/x/text/encoding/unicode"
+)
+
+// textEncodings are the encodings that can be chosen for uploads, keyed by
+// form value, with the byte order mark each may start with.
+var textEncodings = map[string]struct {
+	label string
+	bom   string
+	enc   encoding.Encoding
+}{
+	"utf-8":        {"UTF-8", "\xef\xbb\xbf", xunicode.UTF8},
+	"utf-16le":     {"UTF-16LE", "\xff\xfe", xunicode.UTF16(xunicode.LittleEndian, xunicode.IgnoreBOM)},
+	"utf-16be":     {"UTF-16BE", "\xfe\xff", xunicode.UTF16(xunicode.BigEndian, xunicode.IgnoreBOM)},
+	"latin1":       {"Latin-1", "", charmap.ISO8859_1},
+	"windows-1252": {"Windows-1252", "", charmap.Windows1252},
+	"shift_jis":    {"Shift-JIS", "", japanese.ShiftJIS},
+}
+
+// utf16Sniff is how many leading bytes are inspected for BOM-less UTF-16.
+const utf16Sniff = 1024
+
+// ToUTF8 converts s from the named encoding to UTF-8 and returns the label of
+// the encoding used. With name "" or "auto" BOMs and BOM-less UTF-16 are
+// detected and anything else is assumed to be UTF-8 already.
+func ToUTF8(s, name string) (string, string, error) {
+	auto := name == "" || name == "auto"
+	if auto {
+		if IsImage(s) {
+			// Image headers contain NULs that can pass for UTF-16
+			return s, "unknown", nil
+		}
+		name = detectEncoding(s)
+		if name == "utf-8" && !utf8.ValidString(s) {
+			// Leave binary content alone for binary mode
+			return s, "unknown", nil
+		}
+	}
+
+	te, ok := textEncodings[name]
+	if !ok {
+		return "", "", fmt.Errorf("unknown encoding %q", name)
+	}
+	body, label := s, te.label
+	if te.bom != "" && strings.HasPrefix(s, te.bom) {
+		body = s[len(te.bom):]
+		label += " (BOM)"
+	}
+
+	out, err := te.enc.NewDecoder().String(body)
+	if err != nil {
+		return "", "", fmt.Errorf("%s: %w", te.label, err)
+	}
+	if auto && name != "utf-8" && !plausiblyDecoded([]byte(out)) {
+		// NUL-heavy binary data that only resembles UTF-16
+		return s, "unknown", nil
+	}
+	return out, label, nil
+}
+
+// detectEncoding returns the textEncodings key for s based on its BOM or the
+// position of NUL bytes, defaulting to "utf-8".
+func detectEncoding(s string) string {
+	switch {
+	case strings.HasPrefix(s, "\xff\xfe"):
+		return "utf-16le"
+	case strings.HasPrefix(s, "\xfe\xff"):
+		return "utf-16be"
+	}
+
+	// ASCII-heavy UTF-16 has a NUL in every other byte
+	n := min(len(s), utf16Sniff) &^ 1
+	if n < 4 {
+		return "utf-8"
+	}
+	var evenNUL, oddNUL int
+	for i := 0; i < n; i++ {
+		if s[i] == 0 {
+			if i%2 == 0 {
+				evenNUL++
+			} else {
+				oddNUL++
+			}
+		}
+	}
+	pairs := n / 2
+	switch {
+	case oddNUL*10 >= pairs*4 && evenNUL*20 < pairs:
+		return "utf-16le"
+	case evenNUL*10 >= pairs*4 && oddNUL*20 < pairs:
+		return "utf-16be"
+	}
+	return "utf-8"
+}
@@ -1,8 +1,12 @@
 package utils
 
 import (
+	"fmt"
 	"io"
+	"mime"
+	"mime/multipart"
 	"path/filepath"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
@@ -27,3 +31,48 @@ func ReadGinFile(c *gin.Context, field string) (string, string) {
 
 	return string(b), filepath.Base(fh.Filename)
 }
+
+// ReadGinFiles returns every file uploaded under field keyed by relative path,
+// with any directory shared by all of them stripped. Folder uploads send the
+// path (e.g. "build/css/site.css") as the part's filename; multipart only
+// exposes its base name, so it is read from the raw Content-Disposition.
+func ReadGinFiles(c *gin.Context, field string) (map[string][]byte, error) {
+	form, err := c.MultipartForm()
+	if err != nil || form == nil {
+		return nil, nil
+	}
+
+	files := map[string][]byte{}
+	total := 0
+	for _, fh := range form.File[field] {
+		name := uploadPath(fh)
+		if name == "" {
+			continue // empty input still submits a part
+		}
+		if len(files) >= maxArchiveEntries {
+			return nil, fmt.Errorf("more than %d files", maxArchiveEntries)
+		}
+		f, err := fh.Open()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		b, err := readArchiveEntry(f, &total)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		files[name] = b
+	}
+	return stripCommonRoot(files), nil
+}
+
+func uploadPath(fh *multipart.FileHeader) string {
+	name := fh.Filename
+	if _, params, err := mime.ParseMediaType(fh.Header.Get("Content-Disposition")); err == nil && params["filename"] != "" {
+		name = params["filename"]
+	}
+	if name == "" {
+		return ""
+	}
+	return cleanEntryPath(strings.ReplaceAll(name, "\\", "/"))
+}
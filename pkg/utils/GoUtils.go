@@ -0,0 +1,305 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/printer"
+	"go/scanner"
+	"go/token"
+	"strings"
+
+	"github.com/jroden2/holmes-go/pkg/domain"
+)
+
+type GoOptions struct {
+	IgnoreComments bool
+}
+
+// snippetPackage is prepended to sources without a package clause so pasted
+// snippets can still be parsed.
+const snippetPackage = "package snippet\n\n"
+
+// goFile is a parsed Go source, remembering whether a package clause had to be
+// added so it can be stripped again when printing.
+type goFile struct {
+	fset    *token.FileSet
+	file    *ast.File
+	snippet bool
+	opts    GoOptions
+}
+
+// goDecl is a single top-level declaration broken into the parts that are
+// compared independently.
+type goDecl struct {
+	key       string
+	signature string
+	body      string
+	doc       string
+	full      string
+}
+
+// PrettyGo parses s with go/parser and prints it back in gofmt style,
+// dropping comments when opts.IgnoreComments is set.
+func PrettyGo(s string, opts GoOptions) (string, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return "", nil
+	}
+
+	gf, err := parseGo(s, opts)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := format.Node(&buf, gf.fset, gf.file); err != nil {
+		return "", err
+	}
+
+	out := buf.String()
+	if gf.snippet {
+		out = strings.TrimPrefix(out, snippetPackage)
+	}
+	return out, nil
+}
+
+// DiffGo compares the top-level declarations of a and b by name and reports
+// which were added, removed, or changed in signature, body or doc comment.
+func DiffGo(a, b string, opts GoOptions) ([]domain.PathChange, error) {
+	declsA, err := goDecls(a, opts)
+	if err != nil {
+		return nil, err
+	}
+	declsB, err := goDecls(b, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	byKeyB := make(map[string]goDecl, len(declsB))
+	for _, d := range declsB {
+		byKeyB[d.key] = d
+	}
+	seen := make(map[string]bool, len(declsA))
+
+	out := []domain.PathChange{}
+	for _, da := range declsA {
+		seen[da.key] = true
+		db, ok := byKeyB[da.key]
+		if !ok {
+			out = append(out, domain.PathChange{Path: da.key, A: da.full, Status: "removed"})
+			continue
+		}
+		if goTokens(da.signature) != goTokens(db.signature) {
+			out = append(out, domain.PathChange{Path: da.key + " (signature)", A: da.signature, B: db.signature, Status: "changed"})
+		}
+		if goTokens(da.body) != goTokens(db.body) {
+			out = append(out, domain.PathChange{Path: da.key + " (body)", A: da.body, B: db.body, Status: "changed"})
+		}
+		if da.doc != db.doc {
+			out = append(out, domain.PathChange{Path: da.key + " (doc)", A: da.doc, B: db.doc, Status: "changed"})
+		}
+	}
+	for _, db := range declsB {
+		if !seen[db.key] {
+			out = append(out, domain.PathChange{Path: db.key, B: db.full, Status: "added"})
+		}
+	}
+
+	return out, nil
+}
+
+func parseGo(s string, opts GoOptions) (*goFile, error) {
+	mode := parser.ParseComments
+	if opts.IgnoreComments {
+		mode = 0
+	}
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", s, mode)
+	if err == nil {
+		return &goFile{fset: fset, file: file, opts: opts}, nil
+	}
+
+	// Retry as a snippet without a package clause
+	fset = token.NewFileSet()
+	file, snippetErr := parser.ParseFile(fset, "", snippetPackage+s, mode)
+	if snippetErr != nil {
+		return nil, err
+	}
+	return &goFile{fset: fset, file: file, snippet: true, opts: opts}, nil
+}
+
+func goDecls(s string, opts GoOptions) ([]goDecl, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+
+	gf, err := parseGo(s, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []goDecl
+	for _, decl := range gf.file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			sig := *d
+			sig.Doc = nil
+			sig.Body = nil
+			var body string
+			if d.Body != nil {
+				body = gf.print(d.Body, true)
+			}
+			out = append(out, goDecl{
+				key:       goFuncKey(d),
+				signature: gf.print(&sig, false),
+				body:      body,
+				doc:       gf.doc(d.Doc),
+				full:      gf.print(d, true),
+			})
+
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				doc := d.Doc
+				if len(d.Specs) > 1 || d.Lparen.IsValid() {
+					doc = specDoc(spec)
+				}
+				def := gf.print(specWithoutDoc(spec), true)
+				out = append(out, goDecl{
+					key:       goSpecKey(d.Tok, spec),
+					signature: def,
+					doc:       gf.doc(doc),
+					full:      d.Tok.String() + " " + def,
+				})
+			}
+		}
+	}
+
+	// Keys can repeat, e.g. several func init() or var _ I = (*T)(nil), so
+	// later occurrences are numbered and matched by position
+	count := make(map[string]int, len(out))
+	for i := range out {
+		key := out[i].key
+		if n := count[key]; n > 0 {
+			out[i].key = fmt.Sprintf("%s#%d", key, n)
+		}
+		count[key]++
+	}
+	return out, nil
+}
+
+func goFuncKey(d *ast.FuncDecl) string {
+	if d.Recv == nil || len(d.Recv.List) == 0 {
+		return "func " + d.Name.Name
+	}
+	return fmt.Sprintf("method (%s).%s", goExprString(d.Recv.List[0].Type), d.Name.Name)
+}
+
+// goExprString renders a receiver type expression, e.g. "*T" or "List[T]".
+func goExprString(expr ast.Expr) string {
+	var buf bytes.Buffer
+	_ = printer.Fprint(&buf, token.NewFileSet(), expr)
+	return buf.String()
+}
+
+func goSpecKey(tok token.Token, spec ast.Spec) string {
+	switch s := spec.(type) {
+	case *ast.TypeSpec:
+		return "type " + s.Name.Name
+	case *ast.ValueSpec:
+		// A spec such as "const a, b = 1, 2" is keyed by all of its names
+		names := make([]string, 0, len(s.Names))
+		for _, n := range s.Names {
+			names = append(names, n.Name)
+		}
+		return tok.String() + " " + strings.Join(names, ", ")
+	case *ast.ImportSpec:
+		return "import " + s.Path.Value
+	}
+	return tok.String()
+}
+
+func specDoc(spec ast.Spec) *ast.CommentGroup {
+	switch s := spec.(type) {
+	case *ast.TypeSpec:
+		return s.Doc
+	case *ast.ValueSpec:
+		return s.Doc
+	case *ast.ImportSpec:
+		return s.Doc
+	}
+	return nil
+}
+
+// specWithoutDoc returns a shallow copy of spec without its doc comment, which
+// is compared separately.
+func specWithoutDoc(spec ast.Spec) ast.Spec {
+	switch s := spec.(type) {
+	case *ast.TypeSpec:
+		c := *s
+		c.Doc = nil
+		return &c
+	case *ast.ValueSpec:
+		c := *s
+		c.Doc = nil
+		return &c
+	case *ast.ImportSpec:
+		c := *s
+		c.Doc = nil
+		return &c
+	}
+	return spec
+}
+
+// print formats node in gofmt style, including the comments inside it unless
+// they are ignored.
+func (gf *goFile) print(node any, withComments bool) string {
+	var buf bytes.Buffer
+	var err error
+	if withComments && !gf.opts.IgnoreComments {
+		err = format.Node(&buf, gf.fset, &printer.CommentedNode{Node: node, Comments: gf.file.Comments})
+	} else {
+		err = format.Node(&buf, gf.fset, node)
+	}
+	if err != nil {
+		return ""
+	}
+	return buf.String()
+}
+
+// goTokens reduces printed Go source to its token stream so declarations that
+// only differ in layout (e.g. a one-line vs multi-line struct) compare equal.
+func goTokens(src string) string {
+	var s scanner.Scanner
+	fset := token.NewFileSet()
+	file := fset.AddFile("", fset.Base(), len(src))
+	s.Init(file, []byte(src), nil, scanner.ScanComments)
+
+	var sb strings.Builder
+	for {
+		_, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		if tok == token.SEMICOLON && lit == "\n" {
+			continue
+		}
+		if lit == "" {
+			lit = tok.String()
+		}
+		sb.WriteString(lit)
+		sb.WriteByte(' ')
+	}
+	return sb.String()
+}
+
+func (gf *goFile) doc(cg *ast.CommentGroup) string {
+	if gf.opts.IgnoreComments || cg == nil {
+		return ""
+	}
+	return strings.TrimSpace(cg.Text())
+}
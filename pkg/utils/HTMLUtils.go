@@ -0,0 +1,388 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jroden2/holmes-go/pkg/domain"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+type HTMLOptions struct {
+	IgnoreClassOrder  bool
+	IgnoreScriptStyle bool
+}
+
+// voidElements never have a closing tag when pretty printed.
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true,
+	"hr": true, "img": true, "input": true, "link": true, "meta": true,
+	"source": true, "track": true, "wbr": true,
+}
+
+// PrettyHTML parses s into a DOM, drops insignificant whitespace, sorts
+// attributes and renders one node per line so two documents can be line
+// diffed regardless of their original formatting.
+func PrettyHTML(s string, opts HTMLOptions) (string, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return "", nil
+	}
+
+	doc, err := parseHTML(s, opts)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	for c := doc.FirstChild; c != nil; c = c.NextSibling {
+		writePrettyHTML(&buf, c, 0)
+	}
+
+	return buf.String(), nil
+}
+
+// DiffHTML compares the DOMs of a and b and reports every difference by a
+// CSS-like path, e.g. "html > body > div#main > p:nth-of-type(2)[class]".
+func DiffHTML(a, b string, opts HTMLOptions) ([]domain.PathChange, error) {
+	docA, err := parseHTML(strings.TrimSpace(a), opts)
+	if err != nil {
+		return nil, err
+	}
+	docB, err := parseHTML(strings.TrimSpace(b), opts)
+	if err != nil {
+		return nil, err
+	}
+
+	out := []domain.PathChange{}
+	diffHTMLChildren("", docA, docB, &out)
+	return out, nil
+}
+
+// parseHTML parses full documents as-is and anything without an <html> tag
+// as a body fragment, so snippets are not wrapped in html/head/body.
+func parseHTML(s string, opts HTMLOptions) (*html.Node, error) {
+	doc := &html.Node{Type: html.DocumentNode}
+
+	lower := strings.ToLower(s)
+	if strings.Contains(lower, "<html") || strings.HasPrefix(lower, "<!doctype") {
+		parsed, err := html.Parse(strings.NewReader(s))
+		if err != nil {
+			return nil, err
+		}
+		doc = parsed
+	} else {
+		body := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+		nodes, err := html.ParseFragment(strings.NewReader(s), body)
+		if err != nil {
+			return nil, err
+		}
+		for _, n := range nodes {
+			doc.AppendChild(n)
+		}
+	}
+
+	normalizeHTMLNode(doc, opts, false)
+	return doc, nil
+}
+
+func normalizeHTMLNode(n *html.Node, opts HTMLOptions, preformatted bool) {
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+
+		switch c.Type {
+		case html.TextNode:
+			if !preformatted {
+				c.Data = NormalizeWhitespace(c.Data)
+			}
+			if c.Data == "" {
+				n.RemoveChild(c)
+			}
+
+		case html.CommentNode:
+			c.Data = strings.TrimSpace(c.Data)
+
+		case html.ElementNode:
+			if opts.IgnoreScriptStyle && (c.DataAtom == atom.Script || c.DataAtom == atom.Style) {
+				n.RemoveChild(c)
+				break
+			}
+			normalizeHTMLAttrs(c, opts)
+			normalizeHTMLNode(c, opts, preformatted || isPreformatted(c))
+		}
+
+		c = next
+	}
+}
+
+func normalizeHTMLAttrs(n *html.Node, opts HTMLOptions) {
+	attrs := make([]html.Attribute, 0, len(n.Attr))
+	for _, a := range n.Attr {
+		if opts.IgnoreScriptStyle && a.Key == "style" {
+			continue
+		}
+		if a.Key == "class" {
+			classes := strings.Fields(a.Val)
+			if opts.IgnoreClassOrder {
+				sort.Strings(classes)
+			}
+			a.Val = strings.Join(classes, " ")
+		}
+		attrs = append(attrs, a)
+	}
+
+	sort.Slice(attrs, func(i, j int) bool {
+		return attrKey(attrs[i]) < attrKey(attrs[j])
+	})
+	n.Attr = attrs
+}
+
+func attrKey(a html.Attribute) string {
+	if a.Namespace != "" {
+		return a.Namespace + ":" + a.Key
+	}
+	return a.Key
+}
+
+func isPreformatted(n *html.Node) bool {
+	return n.DataAtom == atom.Pre || n.DataAtom == atom.Textarea
+}
+
+func writePrettyHTML(buf *bytes.Buffer, n *html.Node, depth int) {
+	indent := strings.Repeat("  ", depth)
+
+	switch n.Type {
+	case html.DoctypeNode:
+		buf.WriteString(indent + "<!DOCTYPE " + n.Data + ">\n")
+
+	case html.CommentNode:
+		buf.WriteString(indent + "<!-- " + n.Data + " -->\n")
+
+	case html.TextNode:
+		buf.WriteString(indent + htmlText(n) + "\n")
+
+	case html.ElementNode:
+		buf.WriteString(indent + openTag(n))
+		if voidElements[n.Data] {
+			buf.WriteString("\n")
+			return
+		}
+		if isPreformatted(n) {
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				_ = html.Render(buf, c)
+			}
+			buf.WriteString("</" + n.Data + ">\n")
+			return
+		}
+		if n.FirstChild == nil {
+			buf.WriteString("</" + n.Data + ">\n")
+			return
+		}
+		if n.FirstChild == n.LastChild && n.FirstChild.Type == html.TextNode {
+			buf.WriteString(htmlText(n.FirstChild) + "</" + n.Data + ">\n")
+			return
+		}
+		buf.WriteString("\n")
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			writePrettyHTML(buf, c, depth+1)
+		}
+		buf.WriteString(indent + "</" + n.Data + ">\n")
+	}
+}
+
+// htmlText escapes text content, except inside raw text elements such as
+// <script> and <style> where it is written as-is.
+func htmlText(n *html.Node) string {
+	if p := n.Parent; p != nil && (p.DataAtom == atom.Script || p.DataAtom == atom.Style) {
+		return n.Data
+	}
+	return html.EscapeString(n.Data)
+}
+
+func openTag(n *html.Node) string {
+	var sb strings.Builder
+	sb.WriteString("<" + n.Data)
+	for _, a := range n.Attr {
+		sb.WriteString(fmt.Sprintf(` %s="%s"`, attrKey(a), html.EscapeString(a.Val)))
+	}
+	sb.WriteString(">")
+	return sb.String()
+}
+
+func diffHTMLChildren(path string, a, b *html.Node, out *[]domain.PathChange) {
+	aKids := htmlChildren(a)
+	bKids := htmlChildren(b)
+
+	aKeys := make([]string, len(aKids))
+	for i, n := range aKids {
+		aKeys[i] = htmlNodeKey(n)
+	}
+	bKeys := make([]string, len(bKids))
+	for i, n := range bKids {
+		bKeys[i] = htmlNodeKey(n)
+	}
+
+	for _, op := range alignKeys(aKeys, bKeys) {
+		switch {
+		case op.a >= 0 && op.b >= 0:
+			diffHTMLNode(childPath(path, aKids, op.a), aKids[op.a], bKids[op.b], out)
+		case op.a >= 0:
+			*out = append(*out, domain.PathChange{
+				Path:   childPath(path, aKids, op.a),
+				A:      summarizeHTMLNode(aKids[op.a]),
+				Status: "removed",
+			})
+		default:
+			*out = append(*out, domain.PathChange{
+				Path:   childPath(path, bKids, op.b),
+				B:      summarizeHTMLNode(bKids[op.b]),
+				Status: "added",
+			})
+		}
+	}
+}
+
+func diffHTMLNode(path string, a, b *html.Node, out *[]domain.PathChange) {
+	switch a.Type {
+	case html.ElementNode:
+		diffHTMLAttrs(path, a, b, out)
+		if isPreformatted(a) {
+			ai, bi := innerHTML(a), innerHTML(b)
+			if ai != bi {
+				*out = append(*out, domain.PathChange{Path: path, A: ai, B: bi, Status: "changed"})
+			}
+			return
+		}
+		diffHTMLChildren(path, a, b, out)
+
+	default:
+		if a.Data != b.Data {
+			*out = append(*out, domain.PathChange{Path: path, A: a.Data, B: b.Data, Status: "changed"})
+		}
+	}
+}
+
+func diffHTMLAttrs(path string, a, b *html.Node, out *[]domain.PathChange) {
+	av := map[string]string{}
+	for _, attr := range a.Attr {
+		av[attrKey(attr)] = attr.Val
+	}
+	bv := map[string]string{}
+	for _, attr := range b.Attr {
+		bv[attrKey(attr)] = attr.Val
+	}
+
+	keys := make([]string, 0, len(av)+len(bv))
+	for k := range av {
+		keys = append(keys, k)
+	}
+	for k := range bv {
+		if _, ok := av[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		va, inA := av[k]
+		vb, inB := bv[k]
+		attrPath := path + "[" + k + "]"
+		switch {
+		case inA && !inB:
+			*out = append(*out, domain.PathChange{Path: attrPath, A: va, Status: "removed"})
+		case !inA && inB:
+			*out = append(*out, domain.PathChange{Path: attrPath, B: vb, Status: "added"})
+		case va != vb:
+			*out = append(*out, domain.PathChange{Path: attrPath, A: va, B: vb, Status: "changed"})
+		}
+	}
+}
+
+func htmlChildren(n *html.Node) []*html.Node {
+	var kids []*html.Node
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		kids = append(kids, c)
+	}
+	return kids
+}
+
+// htmlNodeKey is used to align siblings: nodes with equal keys are treated as
+// the same node and compared, everything else is reported as added/removed.
+func htmlNodeKey(n *html.Node) string {
+	switch n.Type {
+	case html.ElementNode:
+		for _, a := range n.Attr {
+			if a.Key == "id" {
+				return n.Data + "#" + a.Val
+			}
+		}
+		return n.Data
+	case html.TextNode:
+		return "::text"
+	case html.CommentNode:
+		return "::comment"
+	case html.DoctypeNode:
+		return "::doctype"
+	}
+	return ""
+}
+
+// childPath builds the CSS-like path segment for kids[i], adding an
+// :nth-of-type() (or a text/comment index) only when it is ambiguous.
+func childPath(parent string, kids []*html.Node, i int) string {
+	n := kids[i]
+	key := htmlNodeKey(n)
+
+	nth, total := 0, 0
+	for j, k := range kids {
+		if sameHTMLType(k, n) {
+			total++
+			if j <= i {
+				nth++
+			}
+		}
+	}
+
+	var seg string
+	switch n.Type {
+	case html.ElementNode:
+		seg = key
+		if !strings.Contains(key, "#") && total > 1 {
+			seg = fmt.Sprintf("%s:nth-of-type(%d)", key, nth)
+		}
+		if parent == "" {
+			return seg
+		}
+		return parent + " > " + seg
+	default:
+		seg = key
+		if total > 1 {
+			seg = fmt.Sprintf("%s(%d)", key, nth)
+		}
+		return parent + seg
+	}
+}
+
+func sameHTMLType(a, b *html.Node) bool {
+	if a.Type != b.Type {
+		return false
+	}
+	return a.Type != html.ElementNode || a.Data == b.Data
+}
+
+func innerHTML(n *html.Node) string {
+	var buf bytes.Buffer
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		_ = html.Render(&buf, c)
+	}
+	return buf.String()
+}
+
+func summarizeHTMLNode(n *html.Node) string {
+	var buf bytes.Buffer
+	_ = html.Render(&buf, n)
+	return truncateRunes(NormalizeWhitespace(buf.String()), 200)
+}
@@ -0,0 +1,219 @@
+package utils
+
+import (
+	"fmt"
+	"mime"
+	"net/textproto"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/jroden2/holmes-go/pkg/domain"
+)
+
+var (
+	httpRequestLine = regexp.MustCompile(`^[A-Z]+ \S+ HTTP/\d(\.\d)?$`)
+	httpStatusLine  = regexp.MustCompile(`^HTTP/\d(\.\d)? \d{3}( .*)?$`)
+)
+
+// httpMessage is a parsed raw HTTP/1.x request or response. Header names are
+// canonicalized so they compare case-insensitively.
+type httpMessage struct {
+	startLine string
+	headers   map[string][]string
+	body      string
+}
+
+// PrettyHTTP parses a raw HTTP/1.x message and writes it back with headers
+// (and repeated header values) sorted and the body pretty-printed according
+// to its Content-Type.
+func PrettyHTTP(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return "", nil
+	}
+
+	msg, err := parseHTTPMessage(s)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	sb.WriteString(msg.startLine + "\n")
+	for _, name := range msg.headerNames() {
+		values := append([]string{}, msg.headers[name]...)
+		sort.Strings(values)
+		for _, v := range values {
+			sb.WriteString(name + ": " + v + "\n")
+		}
+	}
+	if msg.body != "" {
+		sb.WriteString("\n")
+		sb.WriteString(strings.TrimRight(msg.prettyBody(), "\n"))
+		sb.WriteString("\n")
+	}
+	return sb.String(), nil
+}
+
+// DiffHTTP reports changes to the start line, each header (compared as a
+// case-insensitive multimap) and the body of two raw HTTP messages.
+func DiffHTTP(a, b string) ([]domain.PathChange, error) {
+	msgA, err := parseHTTPMessage(strings.TrimSpace(a))
+	if err != nil {
+		return nil, err
+	}
+	msgB, err := parseHTTPMessage(strings.TrimSpace(b))
+	if err != nil {
+		return nil, err
+	}
+
+	out := []domain.PathChange{}
+	if msgA.startLine != msgB.startLine {
+		out = append(out, domain.PathChange{Path: "start line", A: msgA.startLine, B: msgB.startLine, Status: "changed"})
+	}
+
+	for _, name := range unionKeys(msgA.headerNames(), msgB.headerNames()) {
+		va, inA := msgA.headers[name]
+		vb, inB := msgB.headers[name]
+		path := "header " + name
+		switch {
+		case inA && !inB:
+			out = append(out, domain.PathChange{Path: path, A: strings.Join(va, "\n"), Status: "removed"})
+		case !inA && inB:
+			out = append(out, domain.PathChange{Path: path, B: strings.Join(vb, "\n"), Status: "added"})
+		case !sameMultiset(va, vb):
+			out = append(out, domain.PathChange{Path: path, A: strings.Join(va, "\n"), B: strings.Join(vb, "\n"), Status: "changed"})
+		}
+	}
+
+	bodyA, bodyB := msgA.prettyBody(), msgB.prettyBody()
+	if bodyA != bodyB {
+		path := "body (" + msgA.bodyMode() + ")"
+		if msgA.bodyMode() != msgB.bodyMode() {
+			path = "body (" + msgA.bodyMode() + " → " + msgB.bodyMode() + ")"
+		}
+		status := "changed"
+		switch {
+		case bodyA == "":
+			status = "added"
+		case bodyB == "":
+			status = "removed"
+		}
+		out = append(out, domain.PathChange{Path: path, A: truncateRunes(bodyA, 200), B: truncateRunes(bodyB, 200), Status: status})
+	}
+
+	return out, nil
+}
+
+func parseHTTPMessage(s string) (*httpMessage, error) {
+	if s == "" {
+		return &httpMessage{headers: map[string][]string{}}, nil
+	}
+
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	head, body, _ := strings.Cut(s, "\n\n")
+
+	lines := strings.Split(head, "\n")
+	msg := &httpMessage{
+		startLine: strings.TrimSpace(lines[0]),
+		headers:   map[string][]string{},
+		body:      body,
+	}
+	if !httpRequestLine.MatchString(msg.startLine) && !httpStatusLine.MatchString(msg.startLine) {
+		return nil, fmt.Errorf("line 1: expected a request line or status line, found %q", truncateRunes(msg.startLine, 60))
+	}
+
+	last := ""
+	for i, line := range lines[1:] {
+		if line == "" {
+			continue
+		}
+		// obs-fold: continuation of the previous header value
+		if (line[0] == ' ' || line[0] == '\t') && last != "" {
+			vals := msg.headers[last]
+			vals[len(vals)-1] += " " + strings.TrimSpace(line)
+			continue
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("line %d: malformed header %q", i+2, truncateRunes(line, 60))
+		}
+		last = textproto.CanonicalMIMEHeaderKey(strings.TrimSpace(name))
+		msg.headers[last] = append(msg.headers[last], strings.TrimSpace(value))
+	}
+
+	return msg, nil
+}
+
+func (m *httpMessage) headerNames() []string {
+	names := make([]string, 0, len(m.headers))
+	for name := range m.headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// bodyMode picks the comparison mode for the body from its Content-Type.
+func (m *httpMessage) bodyMode() string {
+	ct := ""
+	if v := m.headers["Content-Type"]; len(v) > 0 {
+		ct = v[0]
+	}
+	mediaType, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		mediaType = strings.ToLower(ct)
+	}
+
+	switch {
+	case strings.HasSuffix(mediaType, "json"):
+		return "json"
+	case strings.HasSuffix(mediaType, "xml"):
+		return "xml"
+	case mediaType == "text/html":
+		return "html"
+	default:
+		return "text"
+	}
+}
+
+// prettyBody formats the body for its mode, falling back to the raw body if
+// it does not parse (e.g. a truncated capture).
+func (m *httpMessage) prettyBody() string {
+	var pretty string
+	var err error
+
+	switch m.bodyMode() {
+	case "json":
+		pretty, err = PrettyJSON(m.body)
+	case "xml":
+		pretty, err = PrettyXML(m.body)
+	case "html":
+		pretty, err = PrettyHTML(m.body, HTMLOptions{})
+	default:
+		return m.body
+	}
+	if err != nil {
+		return m.body
+	}
+	return pretty
+}
+
+// sameMultiset reports whether a and b hold the same values regardless of
+// order.
+func sameMultiset(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	counts := make(map[string]int, len(a))
+	for _, v := range a {
+		counts[v]++
+	}
+	for _, v := range b {
+		counts[v]--
+		if counts[v] < 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,243 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"strings"
+
+	"github.com/jroden2/holmes-go/pkg/domain"
+)
+
+// maxImagePixels bounds the size of each decoded image, and of the canvas
+// covering both (about 100MB as RGBA).
+const maxImagePixels = 25_000_000
+
+// imageCell is the size of the grid used to group changed pixels into
+// regions; changes closer than this end up in the same bounding box.
+const imageCell = 8
+
+// maxImageBoxes caps the number of regions reported.
+const maxImageBoxes = 100
+
+var (
+	maskChanged   = color.NRGBA{R: 255, G: 255, B: 255, A: 255}
+	maskUnchanged = color.NRGBA{A: 255}
+	overlayMark   = color.NRGBA{R: 255, A: 255}
+	overlayBox    = color.NRGBA{R: 255, B: 255, A: 255}
+)
+
+// IsImage reports whether s starts with a PNG, JPEG or GIF signature.
+func IsImage(s string) bool {
+	return strings.HasPrefix(s, "\x89PNG\r\n\x1a\n") ||
+		strings.HasPrefix(s, "\xff\xd8\xff") ||
+		strings.HasPrefix(s, "GIF87a") || strings.HasPrefix(s, "GIF89a")
+}
+
+// DiffImages decodes two PNG, JPEG or GIF images (the first frame of an
+// animation) and compares them pixel by pixel. A pixel is changed when any
+// RGBA channel differs by more than tolerance (0-255); where the sizes differ,
+// pixels present in only one image are changed.
+func DiffImages(a, b string, tolerance int) (*domain.ImageDiff, error) {
+	// The mask and overlay cover both images, so the canvas they span is
+	// bounded before either is decoded
+	cfgA, _, err := image.DecodeConfig(strings.NewReader(a))
+	if err != nil {
+		return nil, fmt.Errorf("A: %w", err)
+	}
+	cfgB, _, err := image.DecodeConfig(strings.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("B: %w", err)
+	}
+	if w, h := max(cfgA.Width, cfgB.Width), max(cfgA.Height, cfgB.Height); w*h > maxImagePixels {
+		return nil, fmt.Errorf("A and B: together they span %dx%d, larger than %d pixels", w, h, maxImagePixels)
+	}
+
+	imgA, formatA, err := decodeImage(a)
+	if err != nil {
+		return nil, fmt.Errorf("A: %w", err)
+	}
+	imgB, formatB, err := decodeImage(b)
+	if err != nil {
+		return nil, fmt.Errorf("B: %w", err)
+	}
+
+	ba, bb := imgA.Bounds(), imgB.Bounds()
+	w, h := max(ba.Dx(), bb.Dx()), max(ba.Dy(), bb.Dy())
+	d := &domain.ImageDiff{
+		AWidth: ba.Dx(), AHeight: ba.Dy(), AFormat: formatA,
+		BWidth: bb.Dx(), BHeight: bb.Dy(), BFormat: formatB,
+		TotalPixels: w * h,
+	}
+
+	mask := image.NewNRGBA(image.Rect(0, 0, w, h))
+	overlay := image.NewNRGBA(image.Rect(0, 0, w, h))
+	cols, rows := (w+imageCell-1)/imageCell, (h+imageCell-1)/imageCell
+	cells := make([]image.Rectangle, cols*rows) // changed pixels per cell
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			pa, inA := pixelAt(imgA, x, y)
+			pb, inB := pixelAt(imgB, x, y)
+			changed := inA != inB || !withinTolerance(pa, pb, tolerance)
+
+			// The overlay shows A (or B beyond A) faded, with changes in red
+			base := pa
+			if !inA {
+				base = pb
+			}
+			overlay.SetNRGBA(x, y, faded(base))
+			mask.SetNRGBA(x, y, maskUnchanged)
+			if !changed {
+				continue
+			}
+			d.ChangedPixels++
+			mask.SetNRGBA(x, y, maskChanged)
+			overlay.SetNRGBA(x, y, overlayMark)
+
+			px := image.Rect(x, y, x+1, y+1)
+			c := &cells[(y/imageCell)*cols+x/imageCell]
+			if c.Empty() {
+				*c = px
+			} else {
+				*c = c.Union(px)
+			}
+		}
+	}
+	if d.TotalPixels > 0 {
+		d.ChangedPercent = float64(d.ChangedPixels) * 100 / float64(d.TotalPixels)
+	}
+
+	for _, r := range changedRegions(cells, cols, rows) {
+		d.Boxes = append(d.Boxes, domain.ImageBox{X: r.Min.X, Y: r.Min.Y, W: r.Dx(), H: r.Dy()})
+		outline(overlay, r, overlayBox)
+	}
+
+	d.A = imageDataURI(formatA, []byte(a))
+	d.B = imageDataURI(formatB, []byte(b))
+	if d.Mask, err = pngDataURI(mask); err != nil {
+		return nil, err
+	}
+	if d.Overlay, err = pngDataURI(overlay); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// decodeImage decodes s as an NRGBA image after checking its dimensions.
+func decodeImage(s string) (*image.NRGBA, string, error) {
+	cfg, format, err := image.DecodeConfig(strings.NewReader(s))
+	if err != nil {
+		return nil, "", err
+	}
+	if cfg.Width*cfg.Height > maxImagePixels {
+		return nil, "", fmt.Errorf("%dx%d is larger than %d pixels", cfg.Width, cfg.Height, maxImagePixels)
+	}
+
+	img, _, err := image.Decode(strings.NewReader(s))
+	if err != nil {
+		return nil, "", err
+	}
+	b := img.Bounds()
+	out := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(out, out.Bounds(), img, b.Min, draw.Src)
+	return out, format, nil
+}
+
+func pixelAt(img *image.NRGBA, x, y int) (color.NRGBA, bool) {
+	if !(image.Point{X: x, Y: y}).In(img.Rect) {
+		return color.NRGBA{}, false
+	}
+	return img.NRGBAAt(x, y), true
+}
+
+func withinTolerance(a, b color.NRGBA, tolerance int) bool {
+	diff := func(x, y uint8) int {
+		if x > y {
+			return int(x - y)
+		}
+		return int(y - x)
+	}
+	return diff(a.R, b.R) <= tolerance && diff(a.G, b.G) <= tolerance &&
+		diff(a.B, b.B) <= tolerance && diff(a.A, b.A) <= tolerance
+}
+
+// faded returns a light greyscale version of c so red highlights stand out.
+func faded(c color.NRGBA) color.NRGBA {
+	grey := (299*int(c.R) + 587*int(c.G) + 114*int(c.B)) / 1000
+	v := uint8(255 - (255-grey)*int(c.A)/255/3)
+	return color.NRGBA{R: v, G: v, B: v, A: 255}
+}
+
+// changedRegions joins neighbouring grid cells (including diagonals) that
+// contain changes and returns the tight bounds of each group, in scan order.
+func changedRegions(cells []image.Rectangle, cols, rows int) []image.Rectangle {
+	seen := make([]bool, len(cells))
+	var out []image.Rectangle
+	for start := range cells {
+		if seen[start] || cells[start].Empty() {
+			continue
+		}
+		if len(out) == maxImageBoxes {
+			break
+		}
+
+		region := cells[start]
+		seen[start] = true
+		stack := []int{start}
+		for len(stack) > 0 {
+			i := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			cx, cy := i%cols, i/cols
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					nx, ny := cx+dx, cy+dy
+					if nx < 0 || ny < 0 || nx >= cols || ny >= rows {
+						continue
+					}
+					j := ny*cols + nx
+					if seen[j] || cells[j].Empty() {
+						continue
+					}
+					seen[j] = true
+					region = region.Union(cells[j])
+					stack = append(stack, j)
+				}
+			}
+		}
+		out = append(out, region)
+	}
+	return out
+}
+
+// outline draws a one pixel border just outside r where it fits.
+func outline(img *image.NRGBA, r image.Rectangle, c color.NRGBA) {
+	r = r.Inset(-1).Intersect(img.Rect)
+	for x := r.Min.X; x < r.Max.X; x++ {
+		img.SetNRGBA(x, r.Min.Y, c)
+		img.SetNRGBA(x, r.Max.Y-1, c)
+	}
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		img.SetNRGBA(r.Min.X, y, c)
+		img.SetNRGBA(r.Max.X-1, y, c)
+	}
+}
+
+func imageDataURI(format string, b []byte) template.URL {
+	return template.URL("data:image/" + format + ";base64," + base64.StdEncoding.EncodeToString(b))
+}
+
+func pngDataURI(img image.Image) (template.URL, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return "", err
+	}
+	return imageDataURI("png", buf.Bytes()), nil
+}
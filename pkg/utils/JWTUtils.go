@@ -0,0 +1,295 @@
+package utils
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/jroden2/holmes-go/pkg/domain"
+)
+
+type JWTOptions struct {
+	// Key is an HMAC secret or a PEM encoded public key/certificate. When
+	// empty signatures are not verified.
+	Key string
+}
+
+// jwtTimeClaims are NumericDate claims rendered as human readable times.
+var jwtTimeClaims = []string{"exp", "iat", "nbf", "auth_time"}
+
+type jwtToken struct {
+	header    map[string]any
+	claims    map[string]any
+	signed    string
+	signature []byte
+}
+
+// PrettyJWT decodes a compact JWS and renders its header and claims as
+// indented JSON, with time claims and the signature check summarized.
+func PrettyJWT(s string, opts JWTOptions) (string, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return "", nil
+	}
+
+	tok, err := decodeJWT(s)
+	if err != nil {
+		return "", err
+	}
+
+	out := map[string]any{
+		"header": tok.header,
+		"claims": tok.claims,
+	}
+	if times := jwtTimes(tok.claims); len(times) > 0 {
+		out["times"] = times
+	}
+	if opts.Key != "" {
+		out["signature"] = tok.verify(opts.Key)
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(out); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// DiffJWT decodes both tokens and structurally diffs their headers and
+// claims, plus the signature check result when a key is given.
+func DiffJWT(a, b string, opts JWTOptions) ([]domain.PathChange, error) {
+	var tokA, tokB *jwtToken
+	var err error
+	if s := strings.TrimSpace(a); s != "" {
+		if tokA, err = decodeJWT(s); err != nil {
+			return nil, err
+		}
+	}
+	if s := strings.TrimSpace(b); s != "" {
+		if tokB, err = decodeJWT(s); err != nil {
+			return nil, err
+		}
+	}
+
+	out := []domain.PathChange{}
+	for _, part := range []string{"header", "claims"} {
+		diffJSONMember(part, tokA.get(part), tokA != nil, tokB.get(part), tokB != nil, &out)
+	}
+
+	for i, c := range out {
+		for _, name := range jwtTimeClaims {
+			if c.Path == "claims."+name {
+				out[i].A = jwtTimeString(c.A)
+				out[i].B = jwtTimeString(c.B)
+			}
+		}
+	}
+
+	if opts.Key != "" && tokA != nil && tokB != nil {
+		va, vb := tokA.verify(opts.Key), tokB.verify(opts.Key)
+		if va != vb {
+			out = append(out, domain.PathChange{Path: "signature", A: va, B: vb, Status: "changed"})
+		}
+	}
+	return out, nil
+}
+
+func decodeJWT(s string) (*jwtToken, error) {
+	s = strings.TrimSpace(strings.TrimPrefix(s, "Bearer "))
+	parts := strings.Split(s, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("not a compact JWS: expected 3 dot-separated parts, found %d", len(parts))
+	}
+
+	tok := &jwtToken{signed: parts[0] + "." + parts[1]}
+	var err error
+	if tok.header, err = decodeJWTSegment(parts[0]); err != nil {
+		return nil, fmt.Errorf("header: %w", err)
+	}
+	if tok.claims, err = decodeJWTSegment(parts[1]); err != nil {
+		return nil, fmt.Errorf("claims: %w", err)
+	}
+	if tok.signature, err = base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[2], "=")); err != nil {
+		return nil, fmt.Errorf("signature: %w", err)
+	}
+	return tok, nil
+}
+
+func decodeJWTSegment(seg string) (map[string]any, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(seg, "="))
+	if err != nil {
+		return nil, err
+	}
+
+	var out map[string]any
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if err := dec.Decode(&out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// get returns the header or claims of t, or nil for a missing token.
+func (t *jwtToken) get(part string) any {
+	if t == nil {
+		return nil
+	}
+	if part == "header" {
+		return t.header
+	}
+	return t.claims
+}
+
+func jwtTimes(claims map[string]any) map[string]string {
+	out := map[string]string{}
+	for _, name := range jwtTimeClaims {
+		if n, ok := claims[name].(json.Number); ok {
+			if secs, err := n.Float64(); err == nil {
+				out[name] = time.Unix(int64(secs), 0).UTC().Format(time.RFC3339)
+			}
+		}
+	}
+	return out
+}
+
+// jwtTimeString annotates a NumericDate value with its RFC3339 time.
+func jwtTimeString(v string) string {
+	secs, err := json.Number(v).Float64()
+	if err != nil {
+		return v
+	}
+	return v + " (" + time.Unix(int64(secs), 0).UTC().Format(time.RFC3339) + ")"
+}
+
+// verify checks the token signature against key and returns a short result
+// such as "valid (HS256)" or "invalid (RS256)".
+func (t *jwtToken) verify(key string) string {
+	alg, _ := t.header["alg"].(string)
+
+	err := verifyJWTSignature(alg, []byte(t.signed), t.signature, key)
+	if err != nil {
+		return fmt.Sprintf("invalid (%s): %s", alg, err)
+	}
+	return fmt.Sprintf("valid (%s)", alg)
+}
+
+func verifyJWTSignature(alg string, signed, sig []byte, key string) error {
+	if alg == "" || alg == "none" {
+		return fmt.Errorf("unsigned token")
+	}
+
+	h, ok := jwtHashes[alg]
+	if !ok && alg != "EdDSA" {
+		return fmt.Errorf("unsupported alg")
+	}
+
+	if strings.HasPrefix(alg, "HS") {
+		if strings.Contains(key, "-----BEGIN") {
+			return fmt.Errorf("HMAC algorithm needs a secret, not a PEM key")
+		}
+		mac := hmac.New(h.New, []byte(key))
+		mac.Write(signed)
+		if !hmac.Equal(mac.Sum(nil), sig) {
+			return fmt.Errorf("signature mismatch")
+		}
+		return nil
+	}
+
+	pub, err := parsePublicKeyPEM(key)
+	if err != nil {
+		return err
+	}
+
+	var digest []byte
+	if h != 0 {
+		hh := h.New()
+		hh.Write(signed)
+		digest = hh.Sum(nil)
+	}
+
+	switch {
+	case strings.HasPrefix(alg, "RS"), strings.HasPrefix(alg, "PS"):
+		k, ok := pub.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("%s needs an RSA public key", alg)
+		}
+		if alg[0] == 'P' {
+			return rsa.VerifyPSS(k, h, digest, sig, nil)
+		}
+		return rsa.VerifyPKCS1v15(k, h, digest, sig)
+
+	case strings.HasPrefix(alg, "ES"):
+		k, ok := pub.(*ecdsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("%s needs an EC public key", alg)
+		}
+		if len(sig)%2 != 0 {
+			return fmt.Errorf("malformed ECDSA signature")
+		}
+		r := new(big.Int).SetBytes(sig[:len(sig)/2])
+		s := new(big.Int).SetBytes(sig[len(sig)/2:])
+		if !ecdsa.Verify(k, digest, r, s) {
+			return fmt.Errorf("signature mismatch")
+		}
+		return nil
+
+	case alg == "EdDSA":
+		k, ok := pub.(ed25519.PublicKey)
+		if !ok {
+			return fmt.Errorf("EdDSA needs an Ed25519 public key")
+		}
+		if !ed25519.Verify(k, signed, sig) {
+			return fmt.Errorf("signature mismatch")
+		}
+		return nil
+	}
+
+	return fmt.Errorf("unsupported algorithm")
+}
+
+// jwtHashes maps each supported alg, except EdDSA, to its hash.
+var jwtHashes = map[string]crypto.Hash{
+	"HS256": crypto.SHA256, "HS384": crypto.SHA384, "HS512": crypto.SHA512,
+	"RS256": crypto.SHA256, "RS384": crypto.SHA384, "RS512": crypto.SHA512,
+	"PS256": crypto.SHA256, "PS384": crypto.SHA384, "PS512": crypto.SHA512,
+	"ES256": crypto.SHA256, "ES384": crypto.SHA384, "ES512": crypto.SHA512,
+}
+
+// parsePublicKeyPEM accepts a PKIX public key, a PKCS#1 RSA public key or a
+// certificate.
+func parsePublicKeyPEM(key string) (any, error) {
+	block, _ := pem.Decode([]byte(strings.TrimSpace(key)))
+	if block == nil {
+		return nil, fmt.Errorf("key is not PEM encoded")
+	}
+
+	switch block.Type {
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return cert.PublicKey, nil
+	default:
+		return x509.ParsePKIXPublicKey(block.Bytes)
+	}
+}
@@ -0,0 +1,155 @@
+package utils
+
+import (
+	"bytes"
+	"html/template"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/jroden2/holmes-go/pkg/domain"
+)
+
+// logVolatile matches tokens that differ between otherwise identical log
+// runs. Alternatives are tried in order, so timestamps win over bare epochs
+// and UUIDs win over hex IDs.
+var logVolatile = regexp.MustCompile(strings.Join([]string{
+	// RFC3339 / ISO8601, e.g. 2026-01-26T10:37:54.123Z or 2026-01-26 10:37:54,123
+	`(?P<TIMESTAMP>\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(?:[.,]\d+)?(?:Z|[+-]\d{2}:?\d{2})?` +
+		// Common/combined log format, e.g. 10/Oct/2000:13:55:36 -0700
+		`|\d{2}/[A-Z][a-z]{2}/\d{4}:\d{2}:\d{2}:\d{2}(?: [+-]\d{4})?` +
+		// Syslog, e.g. Jan  2 15:04:05
+		`|(?:Jan|Feb|Mar|Apr|May|Jun|Jul|Aug|Sep|Oct|Nov|Dec) [ \d]\d \d{2}:\d{2}:\d{2}` +
+		// Time of day, e.g. 15:04:05.000
+		`|\b\d{2}:\d{2}:\d{2}(?:[.,]\d+)?\b)`,
+	`(?P<UUID>\b[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}\b)`,
+	`(?P<IP>\b(?:\d{1,3}\.){3}\d{1,3}(?::\d{1,5})?\b|\b(?:[0-9a-fA-F]{1,4}:){7}[0-9a-fA-F]{1,4}\b|\b(?:[0-9a-fA-F]{1,4}:){1,6}:(?:[0-9a-fA-F]{1,4}\b)?)`,
+	`(?P<EPOCH>\b1\d{9}(?:\d{3}|\d{6}|\d{9})?(?:\.\d+)?\b)`,
+	`(?P<DURATION>\b(?:\d+(?:\.\d+)?(?:ns|us|µs|ms|s|m|h))+\b)`,
+	`(?P<HEX>\b(?:0x)?[0-9a-fA-F]{8,}\b)`,
+}, "|"))
+
+// logSegment is a piece of a log line. Literal text has masked == orig;
+// volatile tokens have masked set to a placeholder such as <UUID>.
+type logSegment struct {
+	orig, masked string
+}
+
+// MaskLog replaces timestamps, UUIDs, IP addresses, epochs, durations and hex
+// IDs in s with placeholders so two log runs can be compared.
+func MaskLog(s string) string {
+	lines := SplitLines(s)
+	for i, line := range lines {
+		var sb strings.Builder
+		for _, seg := range logSegments(line) {
+			sb.WriteString(seg.masked)
+		}
+		lines[i] = sb.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// LogLineDiff compares lines by their masked form but renders the original
+// text, with masked tokens marked so it is clear what was ignored.
+func LogLineDiff(a, b string) []domain.LineDiffRow {
+	return lineDiffRows(SplitLines(a), SplitLines(b), func(av, bv string) bool {
+		return MaskLog(av) == MaskLog(bv)
+	}, renderLogLines)
+}
+
+func logSegments(line string) []logSegment {
+	var out []logSegment
+	names := logVolatile.SubexpNames()
+
+	last := 0
+	for _, m := range logVolatile.FindAllStringSubmatchIndex(line, -1) {
+		name := ""
+		for g := 1; g < len(names); g++ {
+			if m[2*g] >= 0 {
+				name = names[g]
+				break
+			}
+		}
+		token := line[m[0]:m[1]]
+		if name == "HEX" && !strings.ContainsFunc(token, unicode.IsDigit) {
+			// Plain words such as "deadbeef" or "accessed" are not IDs
+			continue
+		}
+
+		if m[0] > last {
+			lit := line[last:m[0]]
+			out = append(out, logSegment{orig: lit, masked: lit})
+		}
+		out = append(out, logSegment{orig: token, masked: "<" + name + ">"})
+		last = m[1]
+	}
+	if last < len(line) {
+		lit := line[last:]
+		out = append(out, logSegment{orig: lit, masked: lit})
+	}
+	return out
+}
+
+func renderLogLines(a, b string, changed bool) (template.HTML, template.HTML) {
+	segsA := logSegments(a)
+	segsB := logSegments(b)
+	if !changed {
+		return renderLogLine(segsA, -1, -1), renderLogLine(segsB, -1, -1)
+	}
+
+	// Find the differing range on the masked text, then map it back onto the
+	// original segments.
+	p, as, bs := charDiffBounds([]rune(joinMasked(segsA)), []rune(joinMasked(segsB)))
+	return renderLogLine(segsA, p, as), renderLogLine(segsB, p, bs)
+}
+
+func joinMasked(segs []logSegment) string {
+	var sb strings.Builder
+	for _, seg := range segs {
+		sb.WriteString(seg.masked)
+	}
+	return sb.String()
+}
+
+// renderLogLine writes the original text of segs, marking masked tokens and
+// highlighting the masked rune range [start, end).
+func renderLogLine(segs []logSegment, start, end int) template.HTML {
+	var buf bytes.Buffer
+	pos := 0
+	for _, seg := range segs {
+		width := len([]rune(seg.masked))
+		segStart, segEnd := pos, pos+width
+		pos = segEnd
+
+		if seg.orig != seg.masked {
+			escaped := template.HTMLEscapeString(seg.orig)
+			title := template.HTMLEscapeString(seg.masked)
+			if segStart < end && segEnd > start {
+				buf.WriteString(`<mark title="` + title + `">` + escaped + `</mark>`)
+			} else {
+				buf.WriteString(`<span class="masked" title="` + title + `">` + escaped + `</span>`)
+			}
+			continue
+		}
+
+		r := []rune(seg.orig)
+		lo := clamp(start-segStart, 0, width)
+		hi := clamp(end-segStart, 0, width)
+		buf.WriteString(template.HTMLEscapeString(string(r[:lo])))
+		if hi > lo {
+			buf.WriteString("<mark>" + template.HTMLEscapeString(string(r[lo:hi])) + "</mark>")
+		}
+		buf.WriteString(template.HTMLEscapeString(string(r[hi:])))
+	}
+	return template.HTML(buf.String())
+}
+
+func clamp(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
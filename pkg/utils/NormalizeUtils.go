@@ -0,0 +1,65 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/jroden2/holmes-go/pkg/domain"
+)
+
+// ruleArrow separates a rule's pattern from its replacement.
+const ruleArrow = "=>"
+
+// ParseNormalizeRules parses one rule per line written as
+// `pattern => replacement`, e.g. `build-\d+ => build-N`. A line without a
+// replacement deletes matches; blank lines and lines starting with # are
+// skipped. Every pattern must compile.
+func ParseNormalizeRules(s string) ([]domain.NormalizeRule, error) {
+	var rules []domain.NormalizeRule
+	for i, line := range SplitLines(s) {
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		pattern, replace, _ := strings.Cut(line, ruleArrow)
+		rule := domain.NormalizeRule{Pattern: strings.TrimSpace(pattern), Replace: strings.TrimSpace(replace)}
+		if rule.Pattern == "" {
+			return nil, fmt.Errorf("line %d: empty pattern", i+1)
+		}
+		if _, err := regexp.Compile(rule.Pattern); err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// CheckNormalizeRules reports the first rule whose pattern is empty or does
+// not compile.
+func CheckNormalizeRules(rules []domain.NormalizeRule) error {
+	for i, rule := range rules {
+		if rule.Pattern == "" {
+			return fmt.Errorf("rule %d: empty pattern", i+1)
+		}
+		if _, err := regexp.Compile(rule.Pattern); err != nil {
+			return fmt.Errorf("rule %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// ApplyNormalizeRules runs each rule over s in order, expanding $1 style
+// references in replacements, and returns the result with the number of
+// matches replaced.
+func ApplyNormalizeRules(s string, rules []domain.NormalizeRule) (string, int, error) {
+	n := 0
+	for _, rule := range rules {
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return "", 0, fmt.Errorf("%s: %w", rule.Pattern, err)
+		}
+		n += len(re.FindAllStringIndex(s, -1))
+		s = re.ReplaceAllString(s, rule.Replace)
+	}
+	return s, n, nil
+}
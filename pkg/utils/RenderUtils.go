@@ -14,8 +14,13 @@ import (
 )
 
 func Render(c *gin.Context, tpl *template.Template, data domain.PageData) {
+	RenderStatus(c, tpl, http.StatusOK, data)
+}
+
+// RenderStatus is Render answering with status.
+func RenderStatus(c *gin.Context, tpl *template.Template, status int, data domain.PageData) {
 	c.Header("Content-Type", "text/html; charset=utf-8")
-	c.Status(http.StatusOK)
+	c.Status(status)
 
 	if err := tpl.Execute(c.Writer, data); err != nil {
 		log.Error().Err(err).Msg("template render failed")
@@ -41,9 +46,14 @@ func SplitLines(s string) []string {
 }
 
 func BasicLineDiffWithHighlight(a, b string) []domain.LineDiffRow {
-	aLines := SplitLines(a)
-	bLines := SplitLines(b)
+	return lineDiffRows(SplitLines(a), SplitLines(b), func(av, bv string) bool {
+		return av == bv
+	}, renderCharDiff)
+}
 
+// lineDiffRows pairs up aLines and bLines by line number. same decides whether
+// two lines match and render produces the HTML for both sides of a row.
+func lineDiffRows(aLines, bLines []string, same func(a, b string) bool, render func(a, b string, changed bool) (template.HTML, template.HTML)) []domain.LineDiffRow {
 	max := len(aLines)
 	if len(bLines) > max {
 		max = len(bLines)
@@ -65,9 +75,9 @@ func BasicLineDiffWithHighlight(a, b string) []domain.LineDiffRow {
 
 		status := "same"
 		switch {
-		case hasA && hasB && av == bv:
+		case hasA && hasB && same(av, bv):
 			status = "same"
-		case hasA && hasB && av != bv:
+		case hasA && hasB:
 			status = "changed"
 		case hasA && !hasB:
 			status = "removed"
@@ -81,15 +91,7 @@ func BasicLineDiffWithHighlight(a, b string) []domain.LineDiffRow {
 			B:       bv,
 			Status:  status,
 		}
-
-		if status == "changed" {
-			aHTML, bHTML := highlightCharDiff(av, bv)
-			row.AHTML = aHTML
-			row.BHTML = bHTML
-		} else {
-			row.AHTML = template.HTML(template.HTMLEscapeString(av))
-			row.BHTML = template.HTML(template.HTMLEscapeString(bv))
-		}
+		row.AHTML, row.BHTML = render(av, bv, status == "changed")
 
 		out = append(out, row)
 	}
@@ -97,23 +99,35 @@ func BasicLineDiffWithHighlight(a, b string) []domain.LineDiffRow {
 	return out
 }
 
-func highlightCharDiff(a, b string) (template.HTML, template.HTML) {
-	ar := []rune(a)
-	br := []rune(b)
+func renderCharDiff(a, b string, changed bool) (template.HTML, template.HTML) {
+	if changed {
+		return highlightCharDiff(a, b)
+	}
+	return template.HTML(template.HTMLEscapeString(a)), template.HTML(template.HTMLEscapeString(b))
+}
 
+// charDiffBounds returns the length of the common prefix of a and b and the
+// start of their common suffix in each, so a[p:as] and b[p:bs] differ.
+func charDiffBounds(a, b []rune) (p, as, bs int) {
 	// common prefix
-	p := 0
-	for p < len(ar) && p < len(br) && ar[p] == br[p] {
+	for p < len(a) && p < len(b) && a[p] == b[p] {
 		p++
 	}
 
 	// common suffix
-	as := len(ar)
-	bs := len(br)
-	for as > p && bs > p && ar[as-1] == br[bs-1] {
+	as = len(a)
+	bs = len(b)
+	for as > p && bs > p && a[as-1] == b[bs-1] {
 		as--
 		bs--
 	}
+	return p, as, bs
+}
+
+func highlightCharDiff(a, b string) (template.HTML, template.HTML) {
+	ar := []rune(a)
+	br := []rune(b)
+	p, as, bs := charDiffBounds(ar, br)
 
 	aPrefix := string(ar[:p])
 	aMid := string(ar[p:as])
@@ -143,3 +157,115 @@ func highlightCharDiff(a, b string) (template.HTML, template.HTML) {
 
 	return template.HTML(bufA.String()), template.HTML(bufB.String())
 }
+
+// alignOp pairs an index in A with an index in B; -1 marks a side where the
+// element is missing (i.e. it was added or removed).
+type alignOp struct {
+	a, b int
+}
+
+// maxAlignCells bounds the table alignKeys builds; larger inputs are paired
+// by position.
+const maxAlignCells = 1 << 20
+
+// alignKeys aligns two key sequences using their longest common subsequence
+// and returns the resulting edit script in order. Only equal keys are paired.
+func alignKeys(a, b []string) []alignOp {
+	// Keys shared at the start and end are paired without the table
+	pre := 0
+	for pre < len(a) && pre < len(b) && a[pre] == b[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(a)-pre && suf < len(b)-pre && a[len(a)-1-suf] == b[len(b)-1-suf] {
+		suf++
+	}
+
+	out := make([]alignOp, 0, len(a)+len(b))
+	for i := 0; i < pre; i++ {
+		out = append(out, alignOp{i, i})
+	}
+	midA, midB := a[pre:len(a)-suf], b[pre:len(b)-suf]
+	var mid []alignOp
+	if len(midA)*len(midB) > maxAlignCells {
+		mid = alignByPosition(midA, midB)
+	} else {
+		mid = alignLCS(midA, midB)
+	}
+	for _, op := range mid {
+		if op.a >= 0 {
+			op.a += pre
+		}
+		if op.b >= 0 {
+			op.b += pre
+		}
+		out = append(out, op)
+	}
+	for k := suf; k > 0; k-- {
+		out = append(out, alignOp{len(a) - k, len(b) - k})
+	}
+	return out
+}
+
+// alignByPosition pairs equal keys at the same position; the others are
+// removed from A and added from B.
+func alignByPosition(a, b []string) []alignOp {
+	out := make([]alignOp, 0, len(a)+len(b))
+	for i := 0; i < len(a) || i < len(b); i++ {
+		switch {
+		case i < len(a) && i < len(b) && a[i] == b[i]:
+			out = append(out, alignOp{i, i})
+		case i < len(b) && i < len(a):
+			out = append(out, alignOp{i, -1}, alignOp{-1, i})
+		case i < len(a):
+			out = append(out, alignOp{i, -1})
+		default:
+			out = append(out, alignOp{-1, i})
+		}
+	}
+	return out
+}
+
+// alignLCS is alignKeys by a full longest common subsequence table.
+func alignLCS(a, b []string) []alignOp {
+	n, m := len(a), len(b)
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	out := make([]alignOp, 0, n+m)
+	i, j := 0, 0
+	for i < n && j < m {
+		switch {
+		case a[i] == b[j]:
+			out = append(out, alignOp{i, j})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			out = append(out, alignOp{i, -1})
+			i++
+		default:
+			out = append(out, alignOp{-1, j})
+			j++
+		}
+	}
+	for ; i < n; i++ {
+		out = append(out, alignOp{i, -1})
+	}
+	for ; j < m; j++ {
+		out = append(out, alignOp{-1, j})
+	}
+	return out
+}
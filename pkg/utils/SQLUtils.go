@@ -0,0 +1,776 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/jroden2/holmes-go/pkg/domain"
+)
+
+type sqlTokenKind int
+
+const (
+	sqlWord sqlTokenKind = iota
+	sqlQuoted
+	sqlString
+	sqlNumber
+	sqlPunct
+)
+
+type sqlToken struct {
+	kind sqlTokenKind
+	text string
+}
+
+// isKeyword reports whether t is an unquoted SQL keyword (already upper-cased
+// by tokenizeSQL).
+func (t sqlToken) isKeyword(kw ...string) bool {
+	if t.kind != sqlWord || !sqlKeywords[t.text] {
+		return false
+	}
+	if len(kw) == 0 {
+		return true
+	}
+	for _, k := range kw {
+		if t.text == k {
+			return true
+		}
+	}
+	return false
+}
+
+var sqlKeywords = toSet(
+	"ADD", "ALL", "ALTER", "AND", "ANY", "AS", "ASC", "AUTO_INCREMENT", "AUTOINCREMENT",
+	"BEGIN", "BETWEEN", "BY", "CASCADE", "CASE", "CHECK", "COLLATE", "COLUMN", "COMMIT",
+	"CONSTRAINT", "CREATE", "CROSS", "CURRENT_TIMESTAMP", "DATABASE", "DEFAULT", "DELETE",
+	"DESC", "DISTINCT", "DROP", "ELSE", "END", "EXISTS", "FALSE", "FOREIGN", "FROM", "FULL",
+	"GENERATED", "GROUP", "HAVING", "IDENTITY", "IF", "IN", "INDEX", "INNER", "INSERT",
+	"INTO", "IS", "JOIN", "KEY", "LEFT", "LIKE", "LIMIT", "MODIFY", "NOT", "NULL", "OFFSET",
+	"ON", "OR", "ORDER", "OUTER", "PRIMARY", "REFERENCES", "RENAME", "REPLACE", "RESTRICT",
+	"RETURNING", "RIGHT", "ROLLBACK", "SCHEMA", "SELECT", "SEQUENCE", "SET", "TABLE",
+	"TEMPORARY", "THEN", "TO", "TRANSACTION", "TRIGGER", "TRUE", "TRUNCATE", "UNION",
+	"UNIQUE", "UPDATE", "USING", "VALUES", "VIEW", "WHEN", "WHERE", "WITH",
+	// Common types, upper-cased like keywords so "varchar" and "VARCHAR" match
+	"BIGINT", "BIGSERIAL", "BINARY", "BLOB", "BOOL", "BOOLEAN", "BYTEA", "CHAR", "CHARACTER",
+	"DATE", "DATETIME", "DECIMAL", "DOUBLE", "FLOAT", "INT", "INTEGER", "INTERVAL", "JSON",
+	"JSONB", "NUMERIC", "PRECISION", "REAL", "SERIAL", "SMALLINT", "TEXT", "TIME",
+	"TIMESTAMP", "TIMESTAMPTZ", "TINYINT", "UUID", "VARBINARY", "VARCHAR", "VARYING", "ZONE",
+	// Common functions
+	"AVG", "CAST", "COALESCE", "COUNT", "LOWER", "MAX", "MIN", "NOW", "SUM", "UPPER",
+)
+
+// sqlSpacedParen lists keywords that keep a space before a following "(";
+// everything else (function calls, types, table names) is written without one.
+var sqlSpacedParen = toSet(
+	"AND", "AS", "BY", "CHECK", "EXISTS", "FROM", "IN", "INTO", "JOIN", "KEY", "NOT", "ON",
+	"OR", "SELECT", "SET", "THEN", "UNIQUE", "USING", "VALUES", "WHEN", "WHERE", "WITH",
+)
+
+func toSet(words ...string) map[string]bool {
+	m := make(map[string]bool, len(words))
+	for _, w := range words {
+		m[w] = true
+	}
+	return m
+}
+
+// PrettySQL tokenizes s, drops comments, upper-cases keywords and collapses
+// whitespace, writing one statement per line.
+func PrettySQL(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return "", nil
+	}
+
+	stmts, err := sqlStatements(s)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	for _, stmt := range stmts {
+		sb.WriteString(joinSQLTokens(stmt))
+		sb.WriteString(";\n")
+	}
+	return sb.String(), nil
+}
+
+// DiffSQL builds a schema model from the DDL statements in a and b and
+// reports table, column, index and constraint changes between them.
+func DiffSQL(a, b string) ([]domain.PathChange, error) {
+	schemaA, err := sqlSchemaOf(a)
+	if err != nil {
+		return nil, err
+	}
+	schemaB, err := sqlSchemaOf(b)
+	if err != nil {
+		return nil, err
+	}
+
+	out := []domain.PathChange{}
+	for _, name := range unionKeys(schemaA.order, schemaB.order) {
+		ta, inA := schemaA.tables[name]
+		tb, inB := schemaB.tables[name]
+		path := "table " + name
+		switch {
+		case !inA && !inB:
+			// Created and dropped again on both sides
+		case inA && !inB:
+			out = append(out, domain.PathChange{Path: path, A: ta.summary(), Status: "removed"})
+		case !inA && inB:
+			out = append(out, domain.PathChange{Path: path, B: tb.summary(), Status: "added"})
+		default:
+			diffSQLParts(path+" > column ", ta.columns, tb.columns, &out)
+			diffSQLParts(path+" > constraint ", ta.constraints, tb.constraints, &out)
+			diffSQLParts(path+" > index ", ta.indexes, tb.indexes, &out)
+		}
+	}
+	return out, nil
+}
+
+func tokenizeSQL(s string) ([]sqlToken, error) {
+	var out []sqlToken
+	r := []rune(s)
+
+	for i := 0; i < len(r); {
+		c := r[i]
+		switch {
+		case unicode.IsSpace(c):
+			i++
+
+		case c == '-' && i+1 < len(r) && r[i+1] == '-':
+			for i < len(r) && r[i] != '\n' {
+				i++
+			}
+
+		case c == '/' && i+1 < len(r) && r[i+1] == '*':
+			end := indexRunes(r, i+2, "*/")
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated comment")
+			}
+			i = end + 2
+
+		case c == '\'' || c == '"' || c == '`':
+			j := i + 1
+			for ; j < len(r); j++ {
+				if r[j] == c {
+					if j+1 < len(r) && r[j+1] == c {
+						j++
+						continue
+					}
+					break
+				}
+			}
+			if j >= len(r) {
+				return nil, fmt.Errorf("unterminated %c quote", c)
+			}
+			kind := sqlQuoted
+			if c == '\'' {
+				kind = sqlString
+			}
+			out = append(out, sqlToken{kind: kind, text: string(r[i : j+1])})
+			i = j + 1
+
+		case c == '$' && i+1 < len(r) && (r[i+1] == '$' || unicode.IsLetter(r[i+1])):
+			// Postgres dollar quoting: $tag$ ... $tag$
+			j := i + 1
+			for j < len(r) && r[j] != '$' && (unicode.IsLetter(r[j]) || unicode.IsDigit(r[j]) || r[j] == '_') {
+				j++
+			}
+			if j >= len(r) || r[j] != '$' {
+				out = append(out, sqlToken{kind: sqlPunct, text: "$"})
+				i++
+				break
+			}
+			tag := string(r[i : j+1])
+			end := indexRunes(r, j+1, tag)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated %s quote", tag)
+			}
+			end += len([]rune(tag))
+			out = append(out, sqlToken{kind: sqlString, text: string(r[i:end])})
+			i = end
+
+		case unicode.IsDigit(c):
+			j := i
+			for j < len(r) && (unicode.IsDigit(r[j]) || r[j] == '.') {
+				j++
+			}
+			out = append(out, sqlToken{kind: sqlNumber, text: string(r[i:j])})
+			i = j
+
+		case unicode.IsLetter(c) || c == '_':
+			j := i
+			for j < len(r) && (unicode.IsLetter(r[j]) || unicode.IsDigit(r[j]) || r[j] == '_' || r[j] == '$') {
+				j++
+			}
+			word := string(r[i:j])
+			if upper := strings.ToUpper(word); sqlKeywords[upper] {
+				word = upper
+			}
+			out = append(out, sqlToken{kind: sqlWord, text: word})
+			i = j
+
+		default:
+			op := string(c)
+			if i+1 < len(r) {
+				switch two := string(r[i : i+2]); two {
+				case "<=", ">=", "<>", "!=", "::", "||", ":=", "->":
+					op = two
+				}
+			}
+			out = append(out, sqlToken{kind: sqlPunct, text: op})
+			i += len([]rune(op))
+		}
+	}
+	return out, nil
+}
+
+// indexRunes returns the index of pat in r at or after from, or -1.
+func indexRunes(r []rune, from int, pat string) int {
+	p := []rune(pat)
+	for i := from; i+len(p) <= len(r); i++ {
+		if string(r[i:i+len(p)]) == pat {
+			return i
+		}
+	}
+	return -1
+}
+
+// sqlStatements splits the token stream on top-level semicolons.
+func sqlStatements(s string) ([][]sqlToken, error) {
+	tokens, err := tokenizeSQL(s)
+	if err != nil {
+		return nil, err
+	}
+
+	var out [][]sqlToken
+	var cur []sqlToken
+	for _, t := range tokens {
+		if t.kind == sqlPunct && t.text == ";" {
+			if len(cur) > 0 {
+				out = append(out, cur)
+			}
+			cur = nil
+			continue
+		}
+		cur = append(cur, t)
+	}
+	if len(cur) > 0 {
+		out = append(out, cur)
+	}
+	return out, nil
+}
+
+func joinSQLTokens(tokens []sqlToken) string {
+	var sb strings.Builder
+	for i, t := range tokens {
+		if i > 0 && sqlNeedsSpace(tokens[i-1], t) {
+			sb.WriteByte(' ')
+		}
+		sb.WriteString(t.text)
+	}
+	return sb.String()
+}
+
+func sqlNeedsSpace(prev, cur sqlToken) bool {
+	if cur.kind == sqlPunct {
+		switch cur.text {
+		case ",", ")", ".", "::":
+			return false
+		case "(":
+			return prev.kind != sqlWord && prev.kind != sqlQuoted || sqlSpacedParen[prev.text]
+		}
+	}
+	if prev.kind == sqlPunct {
+		switch prev.text {
+		case "(", ".", "::":
+			return false
+		}
+	}
+	return true
+}
+
+type sqlSchema struct {
+	tables map[string]*sqlTable
+	order  []string
+}
+
+// sqlTable holds each part of a table as name -> normalized definition, with
+// insertion order kept so changes are reported in declaration order.
+type sqlTable struct {
+	name        string
+	columns     orderedParts
+	constraints orderedParts
+	indexes     orderedParts
+}
+
+type orderedParts struct {
+	defs  map[string]string
+	order []string
+}
+
+func (p *orderedParts) set(name, def string) {
+	if p.defs == nil {
+		p.defs = map[string]string{}
+	}
+	if _, ok := p.defs[name]; !ok {
+		p.order = append(p.order, name)
+	}
+	p.defs[name] = def
+}
+
+func (p *orderedParts) remove(name string) {
+	delete(p.defs, name)
+}
+
+func (t *sqlTable) summary() string {
+	parts := make([]string, 0, len(t.columns.order))
+	for _, name := range t.columns.order {
+		if def, ok := t.columns.defs[name]; ok {
+			parts = append(parts, name+" "+def)
+		}
+	}
+	return truncateRunes(strings.Join(parts, ", "), 200)
+}
+
+func (s *sqlSchema) table(name string) *sqlTable {
+	t, ok := s.tables[name]
+	if !ok {
+		t = &sqlTable{name: name}
+		s.tables[name] = t
+		s.order = append(s.order, name)
+	}
+	return t
+}
+
+func (s *sqlSchema) drop(name string) {
+	delete(s.tables, name)
+}
+
+// sqlSchemaOf replays the DDL statements in s (CREATE/ALTER/DROP TABLE and
+// CREATE/DROP INDEX) into a schema model. Other statements are ignored.
+func sqlSchemaOf(s string) (*sqlSchema, error) {
+	schema := &sqlSchema{tables: map[string]*sqlTable{}}
+
+	stmts, err := sqlStatements(s)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, stmt := range stmts {
+		p := &sqlParser{tokens: stmt}
+		switch {
+		case p.accept("CREATE"):
+			p.accept("TEMPORARY")
+			unique := p.accept("UNIQUE")
+			switch {
+			case p.accept("TABLE"):
+				p.skipIfNotExists()
+				parseSQLCreateTable(schema.table(p.ident()), p)
+			case p.accept("INDEX"):
+				p.skipIfNotExists()
+				parseSQLCreateIndex(schema, p, unique)
+			}
+
+		case p.accept("ALTER") && p.accept("TABLE"):
+			p.accept("IF")
+			p.accept("EXISTS")
+			parseSQLAlterTable(schema.table(p.ident()), p)
+
+		case p.accept("DROP"):
+			switch {
+			case p.accept("TABLE"):
+				p.skipIfExists()
+				schema.drop(p.ident())
+			case p.accept("INDEX"):
+				p.skipIfExists()
+				name := p.ident()
+				for _, t := range schema.tables {
+					t.indexes.remove(name)
+				}
+			}
+		}
+	}
+	return schema, nil
+}
+
+type sqlParser struct {
+	tokens []sqlToken
+	pos    int
+}
+
+func (p *sqlParser) peek() sqlToken {
+	if p.pos >= len(p.tokens) {
+		return sqlToken{kind: sqlPunct}
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *sqlParser) accept(kw string) bool {
+	if p.peek().isKeyword(kw) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// acceptWord is accept for words that are not keywords, such as TYPE, which
+// is also a common column name.
+func (p *sqlParser) acceptWord(w string) bool {
+	if t := p.peek(); t.kind == sqlWord && strings.EqualFold(t.text, w) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *sqlParser) skipIfNotExists() {
+	if p.accept("IF") {
+		p.accept("NOT")
+		p.accept("EXISTS")
+	}
+}
+
+func (p *sqlParser) skipIfExists() {
+	if p.accept("IF") {
+		p.accept("EXISTS")
+	}
+}
+
+// ident reads a possibly schema-qualified identifier, stripping quotes and
+// lower-casing it so "Users", users and `users` are the same table.
+func (p *sqlParser) ident() string {
+	var parts []string
+	for p.pos < len(p.tokens) {
+		t := p.tokens[p.pos]
+		if t.kind != sqlWord && t.kind != sqlQuoted {
+			break
+		}
+		parts = append(parts, sqlIdent(t))
+		p.pos++
+		if p.peek().kind != sqlPunct || p.peek().text != "." {
+			break
+		}
+		p.pos++
+	}
+	return strings.Join(parts, ".")
+}
+
+func sqlIdent(t sqlToken) string {
+	if t.kind == sqlQuoted {
+		return t.text[1 : len(t.text)-1]
+	}
+	return strings.ToLower(t.text)
+}
+
+// group returns the tokens inside the parenthesised group starting at the
+// current position, split on top-level commas.
+func (p *sqlParser) group() [][]sqlToken {
+	if !isSQLPunct(p.peek(), "(") {
+		return nil
+	}
+	p.pos++
+
+	start, depth := p.pos, 0
+	for ; p.pos < len(p.tokens); p.pos++ {
+		switch t := p.tokens[p.pos]; {
+		case isSQLPunct(t, "("):
+			depth++
+		case isSQLPunct(t, ")"):
+			if depth == 0 {
+				p.pos++
+				return splitSQLList(p.tokens[start : p.pos-1])
+			}
+			depth--
+		}
+	}
+	return splitSQLList(p.tokens[start:])
+}
+
+// splitSQLList splits tokens on commas outside parentheses.
+func splitSQLList(tokens []sqlToken) [][]sqlToken {
+	var out [][]sqlToken
+	start, depth := 0, 0
+	for i, t := range tokens {
+		switch {
+		case isSQLPunct(t, "("):
+			depth++
+		case isSQLPunct(t, ")"):
+			depth--
+		case isSQLPunct(t, ",") && depth == 0:
+			out = append(out, tokens[start:i])
+			start = i + 1
+		}
+	}
+	return append(out, tokens[start:])
+}
+
+func isSQLPunct(t sqlToken, text string) bool {
+	return t.kind == sqlPunct && t.text == text
+}
+
+func (p *sqlParser) rest() []sqlToken {
+	out := p.tokens[p.pos:]
+	p.pos = len(p.tokens)
+	return out
+}
+
+func parseSQLCreateTable(t *sqlTable, p *sqlParser) {
+	for _, item := range p.group() {
+		addSQLTableItem(t, item)
+	}
+}
+
+func addSQLTableItem(t *sqlTable, item []sqlToken) {
+	if len(item) == 0 {
+		return
+	}
+
+	first := item[0]
+	switch {
+	case first.isKeyword("CONSTRAINT") && len(item) > 1:
+		t.constraints.set(sqlIdent(item[1]), joinSQLTokens(item[2:]))
+	case first.isKeyword("PRIMARY", "UNIQUE", "FOREIGN", "CHECK"):
+		def := joinSQLTokens(item)
+		t.constraints.set(def, def)
+	case first.isKeyword("KEY", "INDEX") && isSQLInlineIndex(item):
+		// MySQL inline index: KEY [name] (cols); unnamed ones are keyed by
+		// their columns
+		name, cols := "", item[1:]
+		if !isSQLPunct(cols[0], "(") {
+			name, cols = sqlIdent(cols[0]), cols[1:]
+		}
+		def := joinSQLTokens(cols)
+		if name == "" {
+			name = def
+		}
+		t.indexes.set(name, def)
+	default:
+		addSQLColumn(t, item)
+	}
+}
+
+// addSQLColumn records a column: its name followed by its type and
+// attributes.
+func addSQLColumn(t *sqlTable, item []sqlToken) {
+	if len(item) > 0 {
+		t.columns.set(sqlIdent(item[0]), joinSQLTokens(item[1:]))
+	}
+}
+
+// isSQLInlineIndex reports whether item, starting with KEY or INDEX, is an
+// index (an optional name, then a list of columns) rather than a column
+// named key or index, such as "key TEXT" or "index VARCHAR(20)".
+func isSQLInlineIndex(item []sqlToken) bool {
+	rest := item[1:]
+	if len(rest) > 0 && (rest[0].kind == sqlQuoted || rest[0].kind == sqlWord && !rest[0].isKeyword()) {
+		rest = rest[1:]
+	}
+	return len(rest) > 1 && isSQLPunct(rest[0], "(") && (rest[1].kind == sqlWord || rest[1].kind == sqlQuoted)
+}
+
+func parseSQLCreateIndex(s *sqlSchema, p *sqlParser, unique bool) {
+	name := p.ident()
+	if !p.accept("ON") {
+		return
+	}
+	table := p.ident()
+
+	def := joinSQLTokens(p.rest())
+	if unique {
+		def = "UNIQUE " + def
+	}
+	s.table(table).indexes.set(name, def)
+}
+
+// parseSQLAlterTable applies each comma separated action of an ALTER TABLE.
+func parseSQLAlterTable(t *sqlTable, p *sqlParser) {
+	for _, action := range splitSQLList(p.rest()) {
+		parseSQLAlterAction(t, &sqlParser{tokens: action})
+	}
+}
+
+func parseSQLAlterAction(t *sqlTable, p *sqlParser) {
+	switch {
+	case p.accept("ADD"):
+		if p.accept("COLUMN") {
+			p.skipIfNotExists()
+			addSQLColumn(t, p.rest())
+			return
+		}
+		addSQLTableItem(t, p.rest())
+
+	case p.accept("DROP"):
+		switch {
+		case p.accept("CONSTRAINT"):
+			p.skipIfExists()
+			t.constraints.remove(p.ident())
+		case p.accept("INDEX"), p.accept("KEY"):
+			t.indexes.remove(p.ident())
+		default:
+			p.accept("COLUMN")
+			p.skipIfExists()
+			t.columns.remove(p.ident())
+		}
+
+	case p.accept("MODIFY"):
+		// MySQL MODIFY [COLUMN] col def replaces the whole definition
+		p.accept("COLUMN")
+		name := p.ident()
+		if def := joinSQLTokens(p.rest()); def != "" {
+			t.columns.set(name, def)
+		}
+
+	case p.accept("ALTER"):
+		p.accept("COLUMN")
+		name := p.ident()
+		def, ok := t.columns.defs[name]
+		if !ok {
+			// Only a new type can be recorded for a column not seen before
+			if p.acceptWord("TYPE") {
+				t.columns.set(name, joinSQLTokens(sqlBefore(p.rest(), "USING")))
+			}
+			return
+		}
+		t.columns.set(name, alterSQLColumn(def, p))
+
+	case p.accept("RENAME"):
+		if p.accept("COLUMN") {
+			from := p.ident()
+			p.accept("TO")
+			to := p.ident()
+			if def, ok := t.columns.defs[from]; ok {
+				t.columns.remove(from)
+				t.columns.set(to, def)
+			}
+		}
+	}
+}
+
+// sqlColumnAttrs are the keywords that start an attribute of a column after
+// its type.
+var sqlColumnAttrs = toSet(
+	"AUTO_INCREMENT", "AUTOINCREMENT", "CHECK", "COLLATE", "CONSTRAINT", "DEFAULT",
+	"GENERATED", "NOT", "NULL", "PRIMARY", "REFERENCES", "UNIQUE",
+)
+
+// alterSQLColumn applies an ALTER COLUMN sub-action (TYPE, SET DATA TYPE,
+// SET/DROP NOT NULL, SET/DROP DEFAULT) to the column definition def, keeping
+// the parts it does not change.
+func alterSQLColumn(def string, p *sqlParser) string {
+	tokens, err := tokenizeSQL(def)
+	if err != nil {
+		return def
+	}
+	clauses := sqlColumnClauses(tokens)
+
+	switch {
+	case p.acceptWord("TYPE"):
+		clauses[0] = sqlBefore(p.rest(), "USING")
+	case p.accept("SET"):
+		switch next := p.peek(); {
+		case next.isKeyword("NOT"):
+			clauses = append(dropSQLClauses(clauses, "NOT", "NULL"), p.rest())
+		case next.isKeyword("DEFAULT"):
+			clauses = append(dropSQLClauses(clauses, "DEFAULT"), p.rest())
+		case p.acceptWord("DATA"):
+			p.acceptWord("TYPE")
+			clauses[0] = sqlBefore(p.rest(), "USING")
+		}
+	case p.accept("DROP"):
+		switch {
+		case p.accept("NOT"):
+			clauses = dropSQLClauses(clauses, "NOT", "NULL")
+		case p.accept("DEFAULT"):
+			clauses = dropSQLClauses(clauses, "DEFAULT")
+		}
+	}
+
+	var out []sqlToken
+	for _, c := range clauses {
+		out = append(out, c...)
+	}
+	return joinSQLTokens(out)
+}
+
+// sqlColumnClauses splits a column definition into its type followed by one
+// clause per attribute, e.g. INT | NOT NULL | DEFAULT 0.
+func sqlColumnClauses(tokens []sqlToken) [][]sqlToken {
+	clauses := [][]sqlToken{nil}
+	depth := 0
+	for _, t := range tokens {
+		cur := &clauses[len(clauses)-1]
+		switch {
+		case isSQLPunct(t, "("):
+			depth++
+		case isSQLPunct(t, ")"):
+			depth--
+		}
+		// NOT NULL and the value after DEFAULT stay in their clause
+		continues := len(*cur) == 1 && ((*cur)[0].isKeyword("NOT") || (*cur)[0].isKeyword("DEFAULT"))
+		if depth == 0 && len(*cur) > 0 && t.isKeyword() && sqlColumnAttrs[t.text] && !continues {
+			clauses = append(clauses, []sqlToken{t})
+			continue
+		}
+		*cur = append(*cur, t)
+	}
+	return clauses
+}
+
+// dropSQLClauses removes the attribute clauses starting with any of kws,
+// keeping the type.
+func dropSQLClauses(clauses [][]sqlToken, kws ...string) [][]sqlToken {
+	out := clauses[:1]
+	for _, c := range clauses[1:] {
+		if !c[0].isKeyword(kws...) {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// sqlBefore returns the tokens before the first top-level keyword kw.
+func sqlBefore(tokens []sqlToken, kw string) []sqlToken {
+	depth := 0
+	for i, t := range tokens {
+		switch {
+		case isSQLPunct(t, "("):
+			depth++
+		case isSQLPunct(t, ")"):
+			depth--
+		case depth == 0 && t.isKeyword(kw):
+			return tokens[:i]
+		}
+	}
+	return tokens
+}
+
+func diffSQLParts(prefix string, a, b orderedParts, out *[]domain.PathChange) {
+	for _, name := range unionKeys(a.order, b.order) {
+		va, inA := a.defs[name]
+		vb, inB := b.defs[name]
+		switch {
+		case inA && !inB:
+			*out = append(*out, domain.PathChange{Path: prefix + name, A: va, Status: "removed"})
+		case !inA && inB:
+			*out = append(*out, domain.PathChange{Path: prefix + name, B: vb, Status: "added"})
+		case inA && inB && va != vb:
+			*out = append(*out, domain.PathChange{Path: prefix + name, A: va, B: vb, Status: "changed"})
+		}
+	}
+}
+
+// unionKeys returns a followed by the entries of b that are not in a.
+func unionKeys(a, b []string) []string {
+	seen := make(map[string]bool, len(a)+len(b))
+	out := make([]string, 0, len(a)+len(b))
+	for _, k := range append(append([]string{}, a...), b...) {
+		if !seen[k] {
+			seen[k] = true
+			out = append(out, k)
+		}
+	}
+	return out
+}
@@ -0,0 +1,92 @@
+package utils
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jroden2/holmes-go/pkg/domain"
+)
+
+// SetOptions controls how lines are matched in set and multiset modes.
+type SetOptions struct {
+	// Multiset compares how many times each line occurs, not just whether it
+	// occurs
+	Multiset bool
+	// IgnoreWS trims lines and collapses inner whitespace runs
+	IgnoreWS bool
+	// IgnoreCase folds case
+	IgnoreCase bool
+}
+
+// lineCount is one distinct line and the number of times it occurs in each
+// input. Line is the first spelling seen, before normalization.
+type lineCount struct {
+	line string
+	a, b int
+}
+
+// DiffLineSets compares the lines of a and b regardless of order. Lines only
+// in A are "removed", lines only in B "added", and in multiset mode lines
+// occurring a different number of times are "changed". Each change is keyed
+// by the line and reports its count on each side. Blank lines are ignored.
+func DiffLineSets(a, b string, opts SetOptions) ([]domain.PathChange, domain.SetStats) {
+	counts := map[string]*lineCount{}
+	var keys []string
+	add := func(s string, side func(*lineCount)) {
+		for _, line := range SplitLines(s) {
+			key := line
+			if opts.IgnoreWS {
+				key = NormalizeWhitespace(key)
+			}
+			if opts.IgnoreCase {
+				key = strings.ToLower(key)
+			}
+			if strings.TrimSpace(key) == "" {
+				continue
+			}
+			lc, ok := counts[key]
+			if !ok {
+				lc = &lineCount{line: line}
+				counts[key] = lc
+				keys = append(keys, key)
+			}
+			side(lc)
+		}
+	}
+	add(a, func(lc *lineCount) { lc.a++ })
+	add(b, func(lc *lineCount) { lc.b++ })
+	sort.Strings(keys)
+
+	out := []domain.PathChange{}
+	var stats domain.SetStats
+	for _, key := range keys {
+		lc := counts[key]
+		if lc.a > 0 {
+			stats.ADistinct++
+		}
+		if lc.b > 0 {
+			stats.BDistinct++
+		}
+
+		var status string
+		switch {
+		case lc.b == 0:
+			stats.OnlyA++
+			status = "removed"
+		case lc.a == 0:
+			stats.OnlyB++
+			status = "added"
+		default:
+			stats.Common++
+			if opts.Multiset && lc.a != lc.b {
+				stats.CountChanged++
+				status = "changed"
+			}
+		}
+		if status != "" {
+			out = append(out, domain.PathChange{Path: lc.line, A: strconv.Itoa(lc.a), B: strconv.Itoa(lc.b), Status: status})
+		}
+	}
+	return out, stats
+}
@@ -0,0 +1,142 @@
+package utils
+
+import (
+	"github.com/jroden2/holmes-go/pkg/domain"
+)
+
+// maxEditCells bounds the edit distance table (rows x columns) after common
+// prefixes and suffixes are trimmed; larger inputs fall back to lines, then
+// to the rendered rows.
+const maxEditCells = 16_000_000
+
+// ComputeDiffStats summarizes a comparison of a and b: line counts from the
+// rendered rows, path counts from the structural changes, and the edit
+// distance and similarity of the inputs.
+func ComputeDiffStats(a, b string, rows []domain.LineDiffRow, changes []domain.PathChange) domain.DiffStats {
+	var st domain.DiffStats
+	for _, row := range rows {
+		switch row.Status {
+		case "added":
+			st.LinesAdded++
+		case "removed":
+			st.LinesRemoved++
+		case "changed":
+			st.LinesChanged++
+		default:
+			st.LinesUnchanged++
+		}
+	}
+	for _, c := range changes {
+		switch c.Status {
+		case "added":
+			st.PathsAdded++
+		case "removed":
+			st.PathsRemoved++
+		default:
+			st.PathsChanged++
+		}
+	}
+
+	// Similarity by characters where affordable, otherwise by lines
+	ra, rb := []rune(a), []rune(b)
+	la, lb := SplitLines(a), SplitLines(b)
+	if d, ok := editDistance(ra, rb); ok {
+		st.EditDistance, st.EditUnit = d, "chars"
+		st.Similarity = similarity(d, max(len(ra), len(rb)))
+	} else if d, ok := editDistance(la, lb); ok {
+		st.EditDistance, st.EditUnit = d, "lines"
+		st.Similarity = similarity(d, max(len(la), len(lb)))
+	} else {
+		st.EditDistance, st.EditUnit = st.LinesAdded+st.LinesRemoved+st.LinesChanged, "lines"
+		st.Similarity = similarity(st.EditDistance, len(rows))
+		st.Approximate = true
+	}
+
+	// Line insertions and deletions turning A into B
+	if n, ok := lineEditScript(la, lb); ok {
+		st.EditScript = n
+	} else {
+		st.EditScript = st.LinesAdded + st.LinesRemoved + 2*st.LinesChanged
+		st.Approximate = true
+	}
+	return st
+}
+
+// DiffStatsForBytes summarizes a binary comparison, where the distance is
+// the number of differing bytes.
+func DiffStatsForBytes(a, b string, diffBytes int) domain.DiffStats {
+	return domain.DiffStats{
+		EditDistance: diffBytes,
+		EditUnit:     "bytes",
+		Similarity:   similarity(diffBytes, max(len(a), len(b))),
+	}
+}
+
+func similarity(distance, length int) float64 {
+	if length == 0 {
+		return 100
+	}
+	return 100 * (1 - float64(distance)/float64(length))
+}
+
+// trimCommon drops the common prefix and suffix of a and b.
+func trimCommon[T comparable](a, b []T) ([]T, []T) {
+	for len(a) > 0 && len(b) > 0 && a[0] == b[0] {
+		a, b = a[1:], b[1:]
+	}
+	for len(a) > 0 && len(b) > 0 && a[len(a)-1] == b[len(b)-1] {
+		a, b = a[:len(a)-1], b[:len(b)-1]
+	}
+	return a, b
+}
+
+// editDistance returns the Levenshtein distance between a and b, or false
+// when the inputs are too large.
+func editDistance[T comparable](a, b []T) (int, bool) {
+	a, b = trimCommon(a, b)
+	if len(a)*len(b) > maxEditCells {
+		return 0, false
+	}
+
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)], true
+}
+
+// lineEditScript returns the number of insertions and deletions in a
+// shortest edit script between a and b, or false when they are too large.
+func lineEditScript(a, b []string) (int, bool) {
+	a, b = trimCommon(a, b)
+	if len(a)*len(b) > maxEditCells {
+		return 0, false
+	}
+
+	// Length of the longest common subsequence
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			if a[i-1] == b[j-1] {
+				cur[j] = prev[j-1] + 1
+			} else {
+				cur[j] = max(prev[j], cur[j-1])
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return len(a) + len(b) - 2*prev[len(b)], true
+}
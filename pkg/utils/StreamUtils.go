@@ -0,0 +1,354 @@
+package utils
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash/fnv"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/jroden2/holmes-go/pkg/domain"
+)
+
+const (
+	// Chunks end after a line whose hash has the low bits in chunkLineMask
+	// clear (about one line in 128), once they hold minChunkSize bytes, and
+	// are cut at maxChunkSize regardless.
+	chunkLineMask = 127
+	minChunkSize  = 8 << 10
+	maxChunkSize  = 1 << 20
+
+	// maxChunkCells bounds the table used to align a run of chunks that has
+	// no chunk unique to both files; larger runs are reported whole. Regions
+	// are aligned by line within the same bound.
+	maxChunkCells = 1 << 20
+)
+
+// ChunkStream copies r to w, splitting it into content-defined chunks of whole
+// lines, and returns the chunks with the size, line count and SHA-256 of the
+// whole stream. Only one chunk is held in memory at a time.
+func ChunkStream(r io.Reader, w io.Writer) ([]domain.StreamChunk, domain.StreamFile, error) {
+	var (
+		chunks   []domain.StreamChunk
+		file     domain.StreamFile
+		cur      = domain.StreamChunk{Line: 1}
+		chunkSum = sha256.New()
+		fileSum  = sha256.New()
+		lineSum  = fnv.New64a()
+		openLine bool // bytes read since the last newline
+	)
+	cut := func() {
+		if cur.Size == 0 {
+			return
+		}
+		copy(cur.Hash[:], chunkSum.Sum(nil))
+		chunks = append(chunks, cur)
+		cur = domain.StreamChunk{Offset: cur.Offset + cur.Size, Line: cur.Line + cur.Lines}
+		chunkSum.Reset()
+	}
+
+	br := bufio.NewReaderSize(r, 64<<10)
+	for {
+		piece, err := br.ReadSlice('\n')
+		if len(piece) > 0 {
+			if _, werr := w.Write(piece); werr != nil {
+				return nil, file, werr
+			}
+			chunkSum.Write(piece)
+			fileSum.Write(piece)
+			lineSum.Write(piece)
+			cur.Size += int64(len(piece))
+			file.Size += int64(len(piece))
+
+			openLine = piece[len(piece)-1] != '\n'
+			if !openLine {
+				cur.Lines++
+				file.Lines++
+				if cur.Size >= minChunkSize && lineSum.Sum64()&chunkLineMask == 0 {
+					cut()
+				}
+				lineSum.Reset()
+			}
+			if cur.Size >= maxChunkSize {
+				cut()
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil && !errors.Is(err, bufio.ErrBufferFull) {
+			return nil, file, err
+		}
+	}
+	if openLine {
+		// A last line without a newline still counts
+		cur.Lines++
+		file.Lines++
+	}
+	cut()
+	file.SHA256 = hex.EncodeToString(fileSum.Sum(nil))
+	file.Chunks = len(chunks)
+	return chunks, file, nil
+}
+
+// AlignChunks matches identical chunks of a and b in order and returns the
+// regions between matches. Chunks unique to both files anchor the alignment;
+// runs between anchors are aligned exactly when small enough.
+func AlignChunks(a, b []domain.StreamChunk, aFile, bFile domain.StreamFile) []domain.StreamRegion {
+	var matches [][2]int
+	alignChunks(a, b, 0, len(a), 0, len(b), &matches)
+	sort.Slice(matches, func(i, j int) bool { return matches[i][0] < matches[j][0] })
+
+	var regions []domain.StreamRegion
+	i, j := 0, 0
+	for _, m := range append(matches, [2]int{len(a), len(b)}) {
+		if m[0] > i || m[1] > j {
+			regions = append(regions, chunkRegion(a[i:m[0]], b[j:m[1]], a, b, i, j, aFile, bFile))
+		}
+		i, j = m[0]+1, m[1]+1
+	}
+	return regions
+}
+
+// alignChunks appends the matching index pairs of a[a0:a1] and b[b0:b1].
+func alignChunks(a, b []domain.StreamChunk, a0, a1, b0, b1 int, matches *[][2]int) {
+	// Common prefix and suffix
+	for a0 < a1 && b0 < b1 && a[a0].Hash == b[b0].Hash {
+		*matches = append(*matches, [2]int{a0, b0})
+		a0, b0 = a0+1, b0+1
+	}
+	for a0 < a1 && b0 < b1 && a[a1-1].Hash == b[b1-1].Hash {
+		a1, b1 = a1-1, b1-1
+		*matches = append(*matches, [2]int{a1, b1})
+	}
+	if a0 == a1 || b0 == b1 {
+		return
+	}
+
+	if anchors := uniqueAnchors(a, b, a0, a1, b0, b1); len(anchors) > 0 {
+		pa, pb := a0, b0
+		for _, m := range anchors {
+			alignChunks(a, b, pa, m[0], pb, m[1], matches)
+			*matches = append(*matches, m)
+			pa, pb = m[0]+1, m[1]+1
+		}
+		alignChunks(a, b, pa, a1, pb, b1, matches)
+		return
+	}
+	if (a1-a0)*(b1-b0) <= maxChunkCells {
+		lcsChunks(a, b, a0, a1, b0, b1, matches)
+	}
+}
+
+// uniqueAnchors returns the longest increasing run of chunks that occur
+// exactly once in each of a[a0:a1] and b[b0:b1], as index pairs.
+func uniqueAnchors(a, b []domain.StreamChunk, a0, a1, b0, b1 int) [][2]int {
+	type seen struct{ ca, cb, ia, ib int }
+	counts := map[[32]byte]*seen{}
+	for i := a0; i < a1; i++ {
+		s := counts[a[i].Hash]
+		if s == nil {
+			s = &seen{}
+			counts[a[i].Hash] = s
+		}
+		s.ca++
+		s.ia = i
+	}
+	for j := b0; j < b1; j++ {
+		if s := counts[b[j].Hash]; s != nil {
+			s.cb++
+			s.ib = j
+		}
+	}
+
+	var pairs [][2]int
+	for i := a0; i < a1; i++ {
+		if s := counts[a[i].Hash]; s.ca == 1 && s.cb == 1 {
+			pairs = append(pairs, [2]int{s.ia, s.ib})
+		}
+	}
+	return longestIncreasing(pairs)
+}
+
+// longestIncreasing returns the longest subsequence of pairs, which are
+// sorted by their first element, whose second elements increase.
+func longestIncreasing(pairs [][2]int) [][2]int {
+	var tails []int // index in pairs of the smallest tail of each length
+	prev := make([]int, len(pairs))
+	for k, p := range pairs {
+		n := sort.Search(len(tails), func(t int) bool { return pairs[tails[t]][1] >= p[1] })
+		if n > 0 {
+			prev[k] = tails[n-1]
+		} else {
+			prev[k] = -1
+		}
+		if n == len(tails) {
+			tails = append(tails, k)
+		} else {
+			tails[n] = k
+		}
+	}
+	if len(tails) == 0 {
+		return nil
+	}
+	out := make([][2]int, len(tails))
+	for k, n := tails[len(tails)-1], len(tails)-1; n >= 0; k, n = prev[k], n-1 {
+		out[n] = pairs[k]
+	}
+	return out
+}
+
+// lcsChunks appends a longest common subsequence of a[a0:a1] and b[b0:b1].
+func lcsChunks(a, b []domain.StreamChunk, a0, a1, b0, b1 int, matches *[][2]int) {
+	n, m := a1-a0, b1-b0
+	table := make([]int32, (n+1)*(m+1))
+	at := func(i, j int) *int32 { return &table[i*(m+1)+j] }
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[a0+i].Hash == b[b0+j].Hash {
+				*at(i, j) = *at(i+1, j+1) + 1
+			} else {
+				*at(i, j) = max(*at(i+1, j), *at(i, j+1))
+			}
+		}
+	}
+	for i, j := 0, 0; i < n && j < m; {
+		switch {
+		case a[a0+i].Hash == b[b0+j].Hash:
+			*matches = append(*matches, [2]int{a0 + i, b0 + j})
+			i, j = i+1, j+1
+		case *at(i+1, j) >= *at(i, j+1):
+			i++
+		default:
+			j++
+		}
+	}
+}
+
+// chunkRegion spans the unmatched chunks ra and rb, which start at indexes i
+// and j; an empty side is placed where its chunks would start.
+func chunkRegion(ra, rb, a, b []domain.StreamChunk, i, j int, aFile, bFile domain.StreamFile) domain.StreamRegion {
+	var r domain.StreamRegion
+	r.AOffset, r.ALine = chunkStart(a, i, aFile)
+	r.BOffset, r.BLine = chunkStart(b, j, bFile)
+	for _, c := range ra {
+		r.ASize += c.Size
+		r.ALines += c.Lines
+	}
+	for _, c := range rb {
+		r.BSize += c.Size
+		r.BLines += c.Lines
+	}
+	switch {
+	case len(ra) == 0:
+		r.Status = "added"
+	case len(rb) == 0:
+		r.Status = "removed"
+	default:
+		r.Status = "changed"
+	}
+	return r
+}
+
+func chunkStart(chunks []domain.StreamChunk, i int, file domain.StreamFile) (int64, int64) {
+	if i < len(chunks) {
+		return chunks[i].Offset, chunks[i].Line
+	}
+	return file.Size, file.Lines + 1
+}
+
+// RegionLineDiff diffs the text of a region line by line, aligning the sides
+// on their longest common subsequence so that an insertion does not shift
+// every later row. Removed lines followed by added ones are paired as changed.
+// Rows are numbered from aLine, or from bLine for lines only in B. Regions too
+// large to align pair lines by position.
+func RegionLineDiff(a, b string, aLine, bLine int) []domain.LineDiffRow {
+	la, lb := SplitLines(a), SplitLines(b)
+	if len(la)*len(lb) > maxChunkCells {
+		rows := BasicLineDiffWithHighlight(a, b)
+		for i := range rows {
+			rows[i].LineNum += aLine - 1
+		}
+		return rows
+	}
+
+	var rows []domain.LineDiffRow
+	var removed, added []int
+	flush := func() {
+		for k := 0; k < max(len(removed), len(added)); k++ {
+			row := domain.LineDiffRow{Status: "changed"}
+			switch {
+			case k >= len(added):
+				row.LineNum, row.A, row.Status = aLine+removed[k], la[removed[k]], "removed"
+			case k >= len(removed):
+				row.LineNum, row.B, row.Status = bLine+added[k], lb[added[k]], "added"
+			default:
+				row.LineNum, row.A, row.B = aLine+removed[k], la[removed[k]], lb[added[k]]
+			}
+			row.AHTML, row.BHTML = renderCharDiff(row.A, row.B, row.Status == "changed")
+			rows = append(rows, row)
+		}
+		removed, added = removed[:0], added[:0]
+	}
+	for _, op := range alignKeys(la, lb) {
+		switch {
+		case op.a < 0:
+			added = append(added, op.b)
+		case op.b < 0:
+			if len(added) > 0 {
+				flush()
+			}
+			removed = append(removed, op.a)
+		default:
+			flush()
+			row := domain.LineDiffRow{LineNum: aLine + op.a, A: la[op.a], B: lb[op.b], Status: "same"}
+			row.AHTML, row.BHTML = renderCharDiff(row.A, row.B, false)
+			rows = append(rows, row)
+		}
+	}
+	flush()
+	return rows
+}
+
+// TrimContext keeps the rows within n rows of a difference and replaces each
+// run of dropped rows with one row of status "skipped".
+func TrimContext(rows []domain.LineDiffRow, n int) []domain.LineDiffRow {
+	keep := make([]bool, len(rows))
+	for i, row := range rows {
+		if row.Status != "same" {
+			for k := max(i-n, 0); k <= min(i+n, len(rows)-1); k++ {
+				keep[k] = true
+			}
+		}
+	}
+	var out []domain.LineDiffRow
+	for i, row := range rows {
+		switch {
+		case keep[i]:
+			out = append(out, row)
+		case i == 0 || keep[i-1]:
+			out = append(out, domain.LineDiffRow{Status: "skipped"})
+		}
+	}
+	return out
+}
+
+// ReadFileRange reads up to limit bytes of the size bytes at offset in the
+// file at path, reporting whether it stopped short.
+func ReadFileRange(path string, offset, size int64, limit int) (string, bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", false, err
+	}
+	defer f.Close()
+
+	n := min(size, int64(limit))
+	buf := make([]byte, n)
+	if _, err := f.ReadAt(buf, offset); err != nil && !errors.Is(err, io.EOF) {
+		return "", false, err
+	}
+	return string(buf), n < size, nil
+}
@@ -6,8 +6,12 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"encoding/xml"
+	"fmt"
 	"io"
+	"sort"
 	"strings"
+
+	"github.com/jroden2/holmes-go/pkg/domain"
 )
 
 func PrettyJSON(s string) (string, error) {
@@ -32,6 +36,119 @@ func PrettyJSON(s string) (string, error) {
 	return string(out), nil
 }
 
+// DiffJSON structurally diffs two JSON documents by dotted path, e.g.
+// "items[2].price".
+func DiffJSON(a, b string) ([]domain.PathChange, error) {
+	va, err := decodeJSONValue(a)
+	if err != nil {
+		return nil, err
+	}
+	vb, err := decodeJSONValue(b)
+	if err != nil {
+		return nil, err
+	}
+	// An empty input is missing; a literal null is a value
+	out := []domain.PathChange{}
+	diffJSONMember("", va, strings.TrimSpace(a) != "", vb, strings.TrimSpace(b) != "", &out)
+	return out, nil
+}
+
+// decodeJSONValue decodes s keeping numbers exact; empty input is nil.
+func decodeJSONValue(s string) (any, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+	var v any
+	dec := json.NewDecoder(strings.NewReader(s))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// diffJSONMember walks two decoded JSON values, either of which may be
+// missing, and records every difference by dotted path, e.g.
+// "claims.address.city" or "claims.roles[1]". A null that is present is a
+// value, so it differs from a missing one.
+func diffJSONMember(path string, a any, inA bool, b any, inB bool, out *[]domain.PathChange) {
+	switch {
+	case !inA && !inB:
+		return
+	case !inA:
+		*out = append(*out, domain.PathChange{Path: path, B: jsonValueString(b), Status: "added"})
+		return
+	case !inB:
+		*out = append(*out, domain.PathChange{Path: path, A: jsonValueString(a), Status: "removed"})
+		return
+	}
+
+	switch av := a.(type) {
+	case map[string]any:
+		bv, ok := b.(map[string]any)
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(av)+len(bv))
+		for k := range av {
+			keys = append(keys, k)
+		}
+		for k := range bv {
+			if _, ok := av[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			ak, inA := av[k]
+			bk, inB := bv[k]
+			diffJSONMember(joinJSONPath(path, k), ak, inA, bk, inB, out)
+		}
+		return
+
+	case []any:
+		bv, ok := b.([]any)
+		if !ok {
+			break
+		}
+		for i := 0; i < len(av) || i < len(bv); i++ {
+			var ai, bi any
+			if i < len(av) {
+				ai = av[i]
+			}
+			if i < len(bv) {
+				bi = bv[i]
+			}
+			diffJSONMember(fmt.Sprintf("%s[%d]", path, i), ai, i < len(av), bi, i < len(bv), out)
+		}
+		return
+	}
+
+	if sa, sb := jsonValueString(a), jsonValueString(b); sa != sb {
+		*out = append(*out, domain.PathChange{Path: path, A: sa, B: sb, Status: "changed"})
+	}
+}
+
+func joinJSONPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// jsonValueString renders strings as-is and anything else as compact JSON.
+func jsonValueString(v any) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
 func PrettyXML(s string) (string, error) {
 	s = strings.TrimSpace(s)
 	if s == "" {
@@ -72,3 +189,11 @@ func Generate32CharString() (string, error) {
 	}
 	return hex.EncodeToString(b), nil
 }
+
+func truncateRunes(s string, max int) string {
+	r := []rune(s)
+	if len(r) <= max {
+		return s
+	}
+	return string(r[:max]) + "…"
+}
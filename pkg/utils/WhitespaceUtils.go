@@ -0,0 +1,129 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/jroden2/holmes-go/pkg/domain"
+)
+
+// WhitespaceOptions ignores whitespace differences line by line, so unlike
+// NormalizeWhitespace the line structure is kept.
+type WhitespaceOptions struct {
+	// TabWidth expands tabs to spaces at this tab stop; 0 leaves tabs alone
+	TabWidth int
+	// IgnoreTrailing ignores whitespace at the end of lines
+	IgnoreTrailing bool
+	// IgnoreAmount treats any run of whitespace as a single space, and also
+	// ignores trailing whitespace
+	IgnoreAmount bool
+	// IgnoreAll ignores every whitespace character within lines
+	IgnoreAll bool
+	// IgnoreBlankLines drops lines that are empty or whitespace only
+	IgnoreBlankLines bool
+}
+
+// Active reports whether any option is set.
+func (o WhitespaceOptions) Active() bool {
+	return o != WhitespaceOptions{}
+}
+
+// NormalizeLines applies o to every line of s.
+func NormalizeLines(s string, o WhitespaceOptions) string {
+	lines, _ := whitespaceLines(s, o)
+	for i, line := range lines {
+		lines[i] = normalizeLineWS(line, o)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// WhitespaceLineDiff is BasicLineDiffWithHighlight with lines compared after
+// applying o. Blank lines are left out when ignored and tabs are shown
+// expanded; other ignored whitespace is still shown. Rows keep the line
+// number of A in the input, or of B where A has no line.
+func WhitespaceLineDiff(a, b string, o WhitespaceOptions) []domain.LineDiffRow {
+	aLines, aNums := whitespaceLines(a, o)
+	bLines, bNums := whitespaceLines(b, o)
+	rows := lineDiffRows(aLines, bLines, func(av, bv string) bool {
+		return normalizeLineWS(av, o) == normalizeLineWS(bv, o)
+	}, renderCharDiff)
+	for i := range rows {
+		if i < len(aNums) {
+			rows[i].LineNum = aNums[i]
+		} else {
+			rows[i].LineNum = bNums[i]
+		}
+	}
+	return rows
+}
+
+// whitespaceLines splits s into lines with tabs expanded and, if ignored,
+// blank lines removed. It also returns the 1-based input line of each.
+func whitespaceLines(s string, o WhitespaceOptions) ([]string, []int) {
+	lines := SplitLines(s)
+	out := lines[:0]
+	nums := make([]int, 0, len(lines))
+	for i, line := range lines {
+		if o.IgnoreBlankLines && strings.TrimSpace(line) == "" {
+			continue
+		}
+		if o.TabWidth > 0 {
+			line = ExpandTabs(line, o.TabWidth)
+		}
+		out = append(out, line)
+		nums = append(nums, i+1)
+	}
+	return out, nums
+}
+
+func normalizeLineWS(line string, o WhitespaceOptions) string {
+	switch {
+	case o.IgnoreAll:
+		return strings.Map(func(r rune) rune {
+			if unicode.IsSpace(r) {
+				return -1
+			}
+			return r
+		}, line)
+	case o.IgnoreAmount:
+		var sb strings.Builder
+		space := false
+		for _, r := range strings.TrimRightFunc(line, unicode.IsSpace) {
+			if unicode.IsSpace(r) {
+				space = true
+				continue
+			}
+			if space {
+				sb.WriteByte(' ')
+				space = false
+			}
+			sb.WriteRune(r)
+		}
+		return sb.String()
+	case o.IgnoreTrailing:
+		return strings.TrimRightFunc(line, unicode.IsSpace)
+	default:
+		return line
+	}
+}
+
+// ExpandTabs replaces each tab in line with spaces up to the next multiple of
+// width columns.
+func ExpandTabs(line string, width int) string {
+	if width <= 0 || !strings.Contains(line, "\t") {
+		return line
+	}
+	var sb strings.Builder
+	col := 0
+	for _, r := range line {
+		if r == '\t' {
+			n := width - col%width
+			sb.WriteString(strings.Repeat(" ", n))
+			col += n
+			continue
+		}
+		sb.WriteRune(r)
+		col++
+	}
+	return sb.String()
+}
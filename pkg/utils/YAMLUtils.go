@@ -0,0 +1,113 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/jroden2/holmes-go/pkg/domain"
+	"gopkg.in/yaml.v3"
+)
+
+// PrettyYAML re-encodes every document in s with sorted keys and two space
+// indentation.
+func PrettyYAML(s string) (string, error) {
+	docs, err := decodeYAML(s)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	for i, doc := range docs {
+		if i > 0 {
+			buf.WriteString("---\n")
+		}
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		if err := enc.Encode(doc); err != nil {
+			return "", err
+		}
+		enc.Close()
+	}
+	return buf.String(), nil
+}
+
+// DiffYAML structurally diffs two YAML inputs by dotted path. Multi-document
+// inputs are addressed as doc[i].
+func DiffYAML(a, b string) ([]domain.PathChange, error) {
+	docsA, err := decodeYAML(a)
+	if err != nil {
+		return nil, err
+	}
+	docsB, err := decodeYAML(b)
+	if err != nil {
+		return nil, err
+	}
+
+	out := []domain.PathChange{}
+	if len(docsA) <= 1 && len(docsB) <= 1 {
+		diffJSONMember("", firstDoc(docsA), len(docsA) > 0, firstDoc(docsB), len(docsB) > 0, &out)
+		return out, nil
+	}
+	for i := 0; i < len(docsA) || i < len(docsB); i++ {
+		var da, db any
+		if i < len(docsA) {
+			da = docsA[i]
+		}
+		if i < len(docsB) {
+			db = docsB[i]
+		}
+		diffJSONMember(fmt.Sprintf("doc[%d]", i), da, i < len(docsA), db, i < len(docsB), &out)
+	}
+	return out, nil
+}
+
+func decodeYAML(s string) ([]any, error) {
+	var docs []any
+	dec := yaml.NewDecoder(strings.NewReader(s))
+	for {
+		var v any
+		err := dec.Decode(&v)
+		if errors.Is(err, io.EOF) {
+			return docs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, normalizeYAML(v))
+	}
+}
+
+// normalizeYAML converts non-string mapping keys (e.g. integers) to strings so
+// documents can be diffed like JSON.
+func normalizeYAML(v any) any {
+	switch vv := v.(type) {
+	case map[string]any:
+		for k, val := range vv {
+			vv[k] = normalizeYAML(val)
+		}
+		return vv
+	case map[any]any:
+		out := make(map[string]any, len(vv))
+		for k, val := range vv {
+			out[fmt.Sprint(k)] = normalizeYAML(val)
+		}
+		return out
+	case []any:
+		for i, val := range vv {
+			vv[i] = normalizeYAML(val)
+		}
+		return vv
+	default:
+		return v
+	}
+}
+
+func firstDoc(docs []any) any {
+	if len(docs) == 0 {
+		return nil
+	}
+	return docs[0]
+}
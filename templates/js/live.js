@@ -0,0 +1,164 @@
+// Live mode: while the "Live" switch is on, edits to the compare form are
+// posted to /live/:id and the comparisons streamed back from
+// /live/:id/events replace the side-by-side rows, without reloading the page.
+(function () {
+    "use strict";
+
+    var toggle = document.getElementById("liveMode");
+    if (!toggle || !window.EventSource || !window.fetch) {
+        return;
+    }
+    var form = toggle.form;
+    var status = document.getElementById("liveStatus");
+    var rows = document.getElementById("diffRows");
+
+    var flags = [
+        "ignore_ws", "ignore_case", "ignore_trailing_ws", "ignore_ws_amount",
+        "ignore_all_ws", "ignore_blank_lines", "ignore_class_order",
+        "ignore_script_style", "ignore_comments"
+    ];
+    var debounce = 250;
+
+    var id = null;
+    var source = null;
+    var timer = null;
+    var shown = 0;
+
+    // rules parses the form's "pattern => replacement" lines like the server.
+    function rules(text) {
+        var out = [];
+        text.split("\n").forEach(function (line) {
+            var trimmed = line.trim();
+            if (trimmed === "" || trimmed.charAt(0) === "#") {
+                return;
+            }
+            var arrow = line.indexOf("=>");
+            if (arrow < 0) {
+                out.push({pattern: trimmed, replace: ""});
+            } else {
+                out.push({pattern: line.slice(0, arrow).trim(), replace: line.slice(arrow + 2).trim()});
+            }
+        });
+        return out;
+    }
+
+    function request() {
+        var data = new FormData(form);
+        var options = {
+            tab_width: parseInt(data.get("tab_width"), 10) || 0,
+            image_tolerance: parseInt(data.get("image_tolerance"), 10) || 0,
+            jwt_key: data.get("jwt_key") || "",
+            decode: data.get("decode") || "",
+            profile: data.get("profile") || "",
+            rules: rules(data.get("rules") || "")
+        };
+        flags.forEach(function (name) {
+            options[name] = data.get(name) === "on";
+        });
+        return {a: data.get("a") || "", b: data.get("b") || "", mode: data.get("mode") || "auto", options: options};
+    }
+
+    function show(text, error) {
+        status.textContent = text;
+        status.classList.remove("d-none");
+        status.classList.toggle("text-danger", !!error);
+    }
+
+    function update(u) {
+        if (u.version < shown) {
+            return;
+        }
+        shown = u.version;
+        if (u.error) {
+            show("Live: " + u.error, true);
+            return;
+        }
+        var text = "Live (" + u.mode + "): exact match " + (u.exact_match ? "YES" : "NO") +
+            " · normalized match " + (u.normalized_match ? "YES" : "NO");
+        if (u.summary) {
+            text += " · " + u.summary;
+        }
+        show(text, false);
+        if (rows) {
+            rows.innerHTML = u.rows;
+        }
+    }
+
+    function send() {
+        if (!id) {
+            return;
+        }
+        fetch("/live/" + id, {
+            method: "POST",
+            headers: {"Content-Type": "application/json"},
+            body: JSON.stringify(request())
+        }).then(function (res) {
+            if (!res.ok) {
+                return res.json().then(function (e) { show("Live: " + e.error, true); });
+            }
+        }).catch(function (err) {
+            show("Live: " + err.message, true);
+        });
+    }
+
+    function schedule() {
+        clearTimeout(timer);
+        timer = setTimeout(send, debounce);
+    }
+
+    function start() {
+        fetch("/live", {method: "POST"}).then(function (res) {
+            return res.json().then(function (body) {
+                if (!res.ok) {
+                    throw new Error(body.error);
+                }
+                return body;
+            });
+        }).then(function (body) {
+            if (!toggle.checked) {
+                return;
+            }
+            id = body.id;
+            shown = 0;
+            source = new EventSource("/live/" + id + "/events");
+            source.addEventListener("diff", function (e) {
+                update(JSON.parse(e.data));
+            });
+            source.onerror = function () {
+                // The session is gone once the server stops retrying
+                if (source.readyState === EventSource.CLOSED) {
+                    stop();
+                    show("Live: connection lost", true);
+                }
+            };
+            send();
+        }).catch(function (err) {
+            toggle.checked = false;
+            show("Live: " + err.message, true);
+        });
+    }
+
+    function stop() {
+        clearTimeout(timer);
+        if (source) {
+            source.close();
+        }
+        id = null;
+        source = null;
+        toggle.checked = false;
+        status.classList.add("d-none");
+    }
+
+    toggle.addEventListener("change", function () {
+        if (toggle.checked) {
+            start();
+        } else {
+            stop();
+        }
+    });
+    form.addEventListener("input", function (e) {
+        if (id && e.target !== toggle && e.target.type !== "file") {
+            schedule();
+        }
+    });
+})();